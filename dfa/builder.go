@@ -1,52 +1,76 @@
 package dfa
 
 import (
-	"errors"
 	"fmt"
 )
 
-type MachineBuilder interface {
-	Path(from string, via string, to string) error
-	Paths(froms []string, via string, tos []string) error
-	WhenEntering(string, func() error) error
-	Accept(state string) error
-	Start(state string) (Machine, error)
-	WhenTransitioningVia(string, func() error)
+// Builds machines that move between states of
+// type S via symbols of type Y.
+type TypedMachineBuilder[S, Y comparable] interface {
+	Path(from S, via Y, to S) error
+	Paths(froms []S, via Y, tos []S) error
+	WhenEntering(S, func() error) error
+	Accept(state S) error
+	Start(state S) (TypedMachine[S, Y], error)
+	WhenTransitioningVia(Y, func() error)
+	// As WhenEntering, but the hook is given the subject of
+	// the machine entering the state, as given to CloneFor.
+	WhenEnteringWith(where S, hook Hook) error
+	// As WhenTransitioningVia, but the hook is given the subject
+	// of the machine transitioning, as given to CloneFor.
+	WhenTransitioningWith(how Y, hook Hook)
+	// Sets how many of the most recent transitions the machine
+	// keeps for DebugRoute, DefaultRouteLimit unless set. Zero
+	// keeps none, so the machine doesn't trace its route at all.
+	TraceRoute(limit int)
 }
 
-type machineBuilder struct {
-	machine *machine
+// Builds machines whose states and symbols are strings, for
+// callers that predate typed machines. Machines keyed by
+// integer types, as the parser's are, move more cheaply.
+type MachineBuilder = TypedMachineBuilder[string, string]
+
+// Builds the graph of states that machines it starts
+// share. The builder mustn't be used once started.
+type machineBuilder[S, Y comparable] struct {
+	graph *graph[S, Y]
 }
 
 func NewMachineBuilder() MachineBuilder {
-	return &machineBuilder{newMachine()}
+	return NewTypedMachineBuilder[string, string]()
+}
+
+func NewTypedMachineBuilder[S, Y comparable]() TypedMachineBuilder[S, Y] {
+	return &machineBuilder[S, Y]{newGraph[S, Y]()}
 }
 
-func (builder *machineBuilder) Path(from string, how string, to string) error {
-	if _, exists := builder.machine.states[from]; !exists {
-		builder.machine.states[from] = newState(from)
+func (builder *machineBuilder[S, Y]) Path(from S, how Y, to S) error {
+	if _, exists := builder.graph.states[from]; !exists {
+		builder.graph.states[from] = newState[S, Y](from)
 	}
 
-	if _, exists := builder.machine.states[to]; !exists {
-		builder.machine.states[to] = newState(to)
+	if _, exists := builder.graph.states[to]; !exists {
+		builder.graph.states[to] = newState[S, Y](to)
 	}
 
-	if _, exists := builder.machine.states[from].paths[how]; exists {
-		// TODO: not panic?!
-		//panic(fmt.Sprintf(`Path "%s" already exists from "%s"`, how, from))
-		return errors.New(fmt.Sprintf(`Path "%s" already exists from "%s"`, how, from))
+	if _, exists := builder.graph.states[from].paths[how]; exists {
+		return builder.mistake(DuplicateMachinePath{fmt.Sprint(from), fmt.Sprint(how)})
 	}
 
-	builder.machine.states[from].paths[how] = builder.machine.states[to]
+	builder.graph.states[from].paths[how] = builder.graph.states[to]
 
 	return nil
 }
 
-func (builder *machineBuilder) WhenTransitioningVia(how string, what func() error) {
-	builder.machine.transitions[how] = what
+func (builder *machineBuilder[S, Y]) WhenTransitioningVia(how Y, what func() error) {
+	builder.WhenTransitioningWith(how, hookOf(what))
 }
 
-func (builder *machineBuilder) Paths(from []string, how string, to []string) error {
+func (builder *machineBuilder[S, Y]) WhenTransitioningWith(how Y, hook Hook) {
+	builder.graph.transitions[how] = hook
+}
+
+func (builder *machineBuilder[S, Y]) Paths(from []S, how Y, to []S) error {
 	for _, f := range from {
 		for _, t := range to {
 			if err := builder.Path(f, how, t); err != nil {
@@ -58,35 +82,56 @@ func (builder *machineBuilder) Paths(from []string, how string, to []string) err
 	return nil
 }
 
-func (builder *machineBuilder) Accept(what string) error {
-	if err := validateState(builder.machine, what); err != nil {
-		return err
+func (builder *machineBuilder[S, Y]) Accept(what S) error {
+	if err := validateState(builder.graph, what); err != nil {
+		return builder.mistake(err)
 	}
 
-	builder.machine.states[what].acceptable = true
+	builder.graph.states[what].acceptable = true
 
 	return nil
 }
 
-func (builder *machineBuilder) WhenEntering(where string, do func() error) error {
-	if err := validateState(builder.machine, where); err != nil {
-		return err
+func (builder *machineBuilder[S, Y]) WhenEntering(where S, do func() error) error {
+	return builder.WhenEnteringWith(where, hookOf(do))
+}
+
+func (builder *machineBuilder[S, Y]) WhenEnteringWith(where S, hook Hook) error {
+	if err := validateState(builder.graph, where); err != nil {
+		return builder.mistake(err)
 	}
 
-	builder.machine.states[where].whenEntering = append(builder.machine.states[where].whenEntering, do)
+	builder.graph.states[where].whenEntering = append(builder.graph.states[where].whenEntering, hook)
 
 	return nil
 }
 
-func (builder *machineBuilder) Start(where string) (Machine, error) {
-	var machine Machine
+// Makes a hook of fn, which ignores the subject,
+// named by FuncName.
+func hookOf(fn func() error) Hook {
+	return Hook{Name: FuncName(fn), Call: func(interface{}) error { return fn() }}
+}
+
+// Records err so that Validate reports it even
+// if whoever is building ignores it.
+func (builder *machineBuilder[S, Y]) mistake(err error) error {
+	builder.graph.mistakes = append(builder.graph.mistakes, err)
+
+	return err
+}
+
+func (builder *machineBuilder[S, Y]) TraceRoute(limit int) {
+	builder.graph.routeLimit = limit
+}
+
+func (builder *machineBuilder[S, Y]) Start(where S) (TypedMachine[S, Y], error) {
+	var machine TypedMachine[S, Y]
 
-	if err := validateState(builder.machine, where); err != nil {
+	if err := validateState(builder.graph, where); err != nil {
 		return machine, err
 	}
 
-	builder.machine.current = builder.machine.states[where]
-	builder.machine.route = append(builder.machine.route, trace{state: *builder.machine.current})
+	builder.graph.start = builder.graph.states[where]
 
-	return builder.machine, nil
+	return newRunner(builder.graph, nil), nil
 }
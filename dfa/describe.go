@@ -0,0 +1,192 @@
+package dfa
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Describes a machine's states, the paths between them,
+// and the names of its hooks, as Describe does.
+type Description struct {
+	Start  string
+	States []StateDescription
+	// The name of the hook called on transitioning via
+	// each symbol that has one.
+	Transitions map[string]string
+}
+
+type StateDescription struct {
+	Name      string
+	Accepting bool
+	// The state the state goes to via each symbol.
+	Paths map[string]string
+	// The names of the hooks called on entering the state.
+	Hooks []string
+}
+
+// Names fn, usually a function or method, without its package
+// or receiver: "createIdentifier" for a method of that name.
+// Anonymous functions are named after what they're in.
+func FuncName(fn interface{}) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+
+	if f == nil {
+		return ""
+	}
+
+	name := strings.TrimSuffix(f.Name(), "-fm")
+	name = name[strings.LastIndex(name, "/")+1:]
+
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+func (machine *machine[S, Y]) Describe() Description {
+	description := Description{Transitions: make(map[string]string)}
+
+	if machine.start != nil {
+		description.Start = fmt.Sprint(machine.start.name)
+	}
+
+	for how, hook := range machine.transitions {
+		description.Transitions[fmt.Sprint(how)] = hook.Name
+	}
+
+	for _, s := range machine.states {
+		described := StateDescription{Name: fmt.Sprint(s.name), Accepting: s.acceptable, Paths: make(map[string]string)}
+
+		for how, to := range s.paths {
+			described.Paths[fmt.Sprint(how)] = fmt.Sprint(to.name)
+		}
+
+		for _, hook := range s.whenEntering {
+			described.Hooks = append(described.Hooks, hook.Name)
+		}
+
+		description.States = append(description.States, described)
+	}
+
+	sort.Slice(description.States, func(i, j int) bool {
+		return description.States[i].Name < description.States[j].Name
+	})
+
+	return description
+}
+
+// An edge of a description's graph: every symbol
+// that goes from one state to another.
+type edge struct {
+	from    string
+	to      string
+	symbols []string
+}
+
+// Gets the edges of the description's graph, in order,
+// labelling symbols with their transition hooks.
+func (d Description) edges() []edge {
+	edges := []edge{}
+
+	for _, s := range d.States {
+		index := make(map[string]int)
+
+		for _, how := range sortedKeys(s.Paths) {
+			to := s.Paths[how]
+
+			if hook, exists := d.Transitions[how]; exists {
+				how = fmt.Sprintf("%s / %s", how, hook)
+			}
+
+			if i, exists := index[to]; exists {
+				edges[i].symbols = append(edges[i].symbols, how)
+			} else {
+				index[to] = len(edges)
+				edges = append(edges, edge{from: s.Name, to: to, symbols: []string{how}})
+			}
+		}
+	}
+
+	return edges
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Writes the description as a Graphviz DOT digraph. Accepting
+// states are double circles and the hooks of each state are
+// listed under its name; edges are labelled with the symbols
+// that move along them and the hooks of those symbols.
+func (d Description) DOT() string {
+	lines := []string{"digraph machine {", "\trankdir=LR;", "\tnode [shape=circle];", `	"" [shape=point];`}
+
+	if d.Start != "" {
+		lines = append(lines, fmt.Sprintf("\t\"\" -> %s;", quoteDOT(d.Start)))
+	}
+
+	for _, s := range d.States {
+		attributes := []string{"label=" + quoteDOT(strings.Join(append([]string{s.Name}, s.Hooks...), "\n"))}
+
+		if s.Accepting {
+			attributes = append(attributes, "shape=doublecircle")
+		}
+
+		lines = append(lines, fmt.Sprintf("\t%s [%s];", quoteDOT(s.Name), strings.Join(attributes, ", ")))
+	}
+
+	for _, e := range d.edges() {
+		lines = append(lines, fmt.Sprintf("\t%s -> %s [label=%s];", quoteDOT(e.from), quoteDOT(e.to), quoteDOT(strings.Join(e.symbols, "\n"))))
+	}
+
+	return strings.Join(append(lines, "}"), "\n") + "\n"
+}
+
+func quoteDOT(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+
+	return `"` + strings.ReplaceAll(s, "\n", `\n`) + `"`
+}
+
+// Writes the description as a Mermaid state diagram. States
+// are given ids as their names may not be valid ones, and
+// are described by their names and hooks.
+func (d Description) Mermaid() string {
+	lines := []string{"stateDiagram-v2"}
+	ids := make(map[string]string)
+
+	for i, s := range d.States {
+		ids[s.Name] = fmt.Sprintf("s%d", i)
+		lines = append(lines, fmt.Sprintf("\t%s : %s", ids[s.Name], quoteMermaid(strings.Join(append([]string{s.Name}, s.Hooks...), "<br>"))))
+	}
+
+	if d.Start != "" {
+		lines = append(lines, fmt.Sprintf("\t[*] --> %s", ids[d.Start]))
+	}
+
+	for _, e := range d.edges() {
+		lines = append(lines, fmt.Sprintf("\t%s --> %s : %s", ids[e.from], ids[e.to], quoteMermaid(strings.Join(e.symbols, "<br>"))))
+	}
+
+	for _, s := range d.States {
+		if s.Accepting {
+			lines = append(lines, fmt.Sprintf("\t%s --> [*]", ids[s.Name]))
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Escapes what Mermaid would otherwise take as syntax.
+func quoteMermaid(s string) string {
+	return strings.NewReplacer(";", "#59;", ":", "#58;", "#", "#35;").Replace(s)
+}
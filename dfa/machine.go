@@ -3,32 +3,90 @@ package dfa
 import (
 	"errors"
 	"fmt"
+	"sort"
 )
 
-type state struct {
-	name         string
-	whenEntering []func() error
-	paths        map[string]*state
+type state[S, Y comparable] struct {
+	name         S
+	whenEntering []Hook
+	paths        map[Y]*state[S, Y]
 	acceptable   bool
 }
 
-type Machine interface {
-	Transition(string) error
+// A function called as a machine moves, with the subject
+// of the machine, if it has one. Its name describes it
+// when the machine is described.
+type Hook struct {
+	Name string
+	Call func(subject interface{}) error
+}
+
+// A machine that moves between states of type S
+// via symbols of type Y.
+type TypedMachine[S, Y comparable] interface {
+	Transition(Y) error
 	Finish() error
+	// Returns the machine to its start state, without
+	// calling any functions for entering it.
+	Reset()
+	// As Reset, but returns the machine to the given
+	// state, failing if the machine has no such state.
+	ResetTo(S) error
+	// Gets a machine at the start state that moves independently
+	// of this one, sharing its states and functions, and subject.
+	// Machines are cheap to clone, unlike to build.
+	Clone() TypedMachine[S, Y]
+	// As Clone, with subject given to the functions added
+	// by WhenEnteringWith and WhenTransitioningWith.
+	CloneFor(subject interface{}) TypedMachine[S, Y]
+	// Gets what the machine can transition via from
+	// its current state, sorted by how they're printed.
+	Outgoing() []Y
 	DebugRoute() string
+	// Describes the machine's states, the paths between
+	// them and its hooks, rather than where it's got to.
+	Describe() Description
+	// Checks the machine's states can all be used, as
+	// an InvalidMachine listing every problem if not.
+	Validate() error
+}
+
+// A machine whose states and symbols are strings,
+// as built by a MachineBuilder.
+type Machine = TypedMachine[string, string]
+
+// How many of the transitions a machine's route keeps
+// for DebugRoute, unless set by the builder's TraceRoute.
+const DefaultRouteLimit = 64
+
+type trace[S, Y comparable] struct {
+	path  Y
+	state S
 }
 
-type trace struct {
-	path  string
-	state state
+// The states of a machine and what's done moving between them,
+// which are shared by the machine's clones once it's started.
+type graph[S, Y comparable] struct {
+	start       *state[S, Y]
+	states      map[S]*state[S, Y]
+	transitions map[Y]Hook
+	routeLimit  int
+	// Errors the builder returned, for Validate
+	// to report if they were ignored.
+	mistakes []error
 }
 
-type machine struct {
-	current     *state
-	states      map[string]*state
-	finished    bool
-	route       []trace
-	transitions map[string]func() error
+type machine[S, Y comparable] struct {
+	*graph[S, Y]
+	current  *state[S, Y]
+	finished bool
+	subject  interface{}
+	// The most recent transitions, from the origin unless the
+	// route has outgrown the limit and truncated is set, when
+	// it's a ring whose oldest transition is at oldest.
+	route     []trace[S, Y]
+	oldest    int
+	truncated bool
 }
 
 type UnacceptableMachineFinishState struct {
@@ -49,6 +107,15 @@ func (err UnknownMachineState) Error() string {
 	return fmt.Sprintf("Unknown machine state: %s", err.state)
 }
 
+type DuplicateMachinePath struct {
+	from string
+	how  string
+}
+
+func (err DuplicateMachinePath) Error() string {
+	return fmt.Sprintf(`Path "%s" already exists from "%s"`, err.how, err.from)
+}
+
 type InvalidMachineTransition struct {
 	from string
 	to   string
@@ -58,40 +125,50 @@ func (err InvalidMachineTransition) Error() string {
 	return fmt.Sprintf("Don't know how to move from %s to %s", err.from, err.to)
 }
 
-func newMachine() *machine {
-	return &machine{states: make(map[string]*state), finished: false, transitions: make(map[string]func() error)}
+func newGraph[S, Y comparable]() *graph[S, Y] {
+	return &graph[S, Y]{states: make(map[S]*state[S, Y]), transitions: make(map[Y]Hook), routeLimit: DefaultRouteLimit}
 }
 
-func newState(name string) *state {
-	return &state{
+func newState[S, Y comparable](name S) *state[S, Y] {
+	return &state[S, Y]{
 		name,
-		make([]func() error, 0),
-		make(map[string]*state),
+		make([]Hook, 0),
+		make(map[Y]*state[S, Y]),
 		false,
 	}
 }
 
-func (machine *machine) Transition(how string) error {
+func (machine *machine[S, Y]) Transition(how Y) error {
 	if machine.finished {
 		return MachineUnusable
 	}
 
-	if _, exists := machine.current.paths[how]; !exists {
-		return InvalidMachineTransition{machine.current.name, how}
+	next, exists := machine.current.paths[how]
+
+	if !exists {
+		return InvalidMachineTransition{fmt.Sprint(machine.current.name), fmt.Sprint(how)}
 	}
 
-	machine.current = machine.current.paths[how]
-	machine.route = append(machine.route, trace{path: how, state: *machine.current})
+	previous := machine.current
+	machine.current = next
+	machine.trace(how)
+
+	// A function that fails leaves the machine where it was, so
+	// what it could have moved via instead can be found. The
+	// route keeps the failed move, to show where it failed.
+	if hook, exists := machine.transitions[how]; exists {
+		if err := hook.Call(machine.subject); err != nil {
+			machine.current = previous
 
-	if fn, exists := machine.transitions[how]; exists {
-		if err := fn(); err != nil {
 			return err
 		}
 	}
 
 	// Call all functions as we enter this new state
-	for _, fn := range machine.current.whenEntering {
-		if err := fn(); err != nil {
+	for _, hook := range machine.current.whenEntering {
+		if err := hook.Call(machine.subject); err != nil {
+			machine.current = previous
+
 			return err
 		}
 	}
@@ -99,7 +176,7 @@ func (machine *machine) Transition(how string) error {
 	return nil
 }
 
-func (machine *machine) Finish() error {
+func (machine *machine[S, Y]) Finish() error {
 	if machine.finished {
 		return MachineUnusable
 	}
@@ -109,27 +186,107 @@ func (machine *machine) Finish() error {
 		return nil
 	}
 
-	return UnacceptableMachineFinishState{machine.current.name}
+	return UnacceptableMachineFinishState{fmt.Sprint(machine.current.name)}
+}
+
+func (machine *machine[S, Y]) Reset() {
+	machine.reset(machine.start)
+}
+
+func (machine *machine[S, Y]) ResetTo(where S) error {
+	if err := validateState(machine.graph, where); err != nil {
+		return err
+	}
+
+	machine.reset(machine.states[where])
+
+	return nil
+}
+
+func (machine *machine[S, Y]) reset(to *state[S, Y]) {
+	machine.current = to
+	machine.finished = false
+	machine.route = machine.route[0:0]
+	machine.oldest = 0
+	machine.truncated = false
+
+	var origin Y
+
+	machine.trace(origin)
+}
+
+// Records the move to the current state via how, if the
+// route is traced, dropping the oldest moves beyond the limit.
+func (machine *machine[S, Y]) trace(how Y) {
+	if machine.routeLimit <= 0 {
+		return
+	}
+
+	moved := trace[S, Y]{path: how, state: machine.current.name}
+
+	// The origin is kept as well as the moves from it.
+	if len(machine.route) <= machine.routeLimit {
+		machine.route = append(machine.route, moved)
+
+		return
+	}
+
+	machine.route[machine.oldest] = moved
+	machine.oldest = (machine.oldest + 1) % len(machine.route)
+	machine.truncated = true
+}
+
+func (machine *machine[S, Y]) Clone() TypedMachine[S, Y] {
+	return machine.CloneFor(machine.subject)
+}
+
+func (machine *machine[S, Y]) CloneFor(subject interface{}) TypedMachine[S, Y] {
+	return newRunner(machine.graph, subject)
+}
+
+func newRunner[S, Y comparable](g *graph[S, Y], subject interface{}) *machine[S, Y] {
+	runner := &machine[S, Y]{graph: g, subject: subject}
+	runner.Reset()
+
+	return runner
+}
+
+func (machine *machine[S, Y]) Outgoing() []Y {
+	outgoing := []Y{}
+
+	for how := range machine.current.paths {
+		outgoing = append(outgoing, how)
+	}
+
+	sort.Slice(outgoing, func(i, j int) bool {
+		return fmt.Sprint(outgoing[i]) < fmt.Sprint(outgoing[j])
+	})
+
+	return outgoing
 }
 
-func (machine *machine) DebugRoute() string {
+func (machine *machine[S, Y]) DebugRoute() string {
 	trace := ""
 
-	for i, element := range machine.route {
-		if i == 0 {
+	for i := range machine.route {
+		element := machine.route[(machine.oldest+i)%len(machine.route)]
+
+		if i == 0 && machine.truncated {
+			trace = fmt.Sprintf("... %v", element.state)
+		} else if i == 0 {
 			// Start state
-			trace = "ORIGIN: " + element.state.name
+			trace = fmt.Sprintf("ORIGIN: %v", element.state)
 		} else {
-			trace = fmt.Sprintf("%s >>%s>> %s", trace, element.path, element.state.name)
+			trace = fmt.Sprintf("%s >>%v>> %v", trace, element.path, element.state)
 		}
 	}
 
 	return trace
 }
 
-func validateState(machine *machine, state string) error {
-	if _, exists := machine.states[state]; !exists {
-		return UnknownMachineState{state}
+func validateState[S, Y comparable](g *graph[S, Y], state S) error {
+	if _, exists := g.states[state]; !exists {
+		return UnknownMachineState{fmt.Sprint(state)}
 	}
 
 	return nil
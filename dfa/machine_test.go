@@ -172,6 +172,81 @@ func TestMachineCannotBeUsedAfterCompletion(t *testing.T) {
 	}
 }
 
+func TestReset(t *testing.T) {
+	builder := getMachineBuilder()
+
+	builder.Path("origin", "next", "middle")
+	builder.Path("middle", "next", "end")
+
+	builder.Accept("end")
+
+	machine := build(builder, "origin", t)
+
+	machine.Transition("next")
+	machine.Reset()
+
+	if err := machine.Finish(); err == nil {
+		t.Error("Expected Finish() to fail after Reset(), but it didn't")
+	}
+
+	machine.Reset()
+	machine.Transition("next")
+	machine.Transition("next")
+
+	if err := machine.Finish(); err != nil {
+		t.Errorf("Expected machine to accept after Reset(), but it didn't: %v", err)
+	}
+
+	if route := machine.DebugRoute(); route != "ORIGIN: origin >>next>> middle >>next>> end" {
+		t.Errorf("Expected route to restart after Reset(), but got: %s", route)
+	}
+}
+
+func TestResetTo(t *testing.T) {
+	builder := getMachineBuilder()
+
+	builder.Path("origin", "next", "middle")
+	builder.Path("middle", "next", "end")
+
+	builder.Accept("end")
+
+	machine := build(builder, "origin", t)
+
+	if err := machine.ResetTo("nowhere"); err == nil {
+		t.Error("Expected ResetTo() to fail for an unknown state, but it didn't")
+	}
+
+	if err := machine.ResetTo("middle"); err != nil {
+		t.Fatalf("Expected ResetTo() to succeed, but it didn't: %v", err)
+	}
+
+	machine.Transition("next")
+
+	if err := machine.Finish(); err != nil {
+		t.Errorf("Expected machine to accept after ResetTo(), but it didn't: %v", err)
+	}
+
+	if route := machine.DebugRoute(); route != "ORIGIN: middle >>next>> end" {
+		t.Errorf("Expected route to restart from middle after ResetTo(), but got: %s", route)
+	}
+}
+
+func TestOutgoing(t *testing.T) {
+	builder := getMachineBuilder()
+
+	builder.Path("origin", "b", "origin")
+	builder.Path("origin", "a", "end")
+	builder.Path("origin", "c", "end")
+
+	machine := build(builder, "origin", t)
+
+	assert.Equal(t, []string{"a", "b", "c"}, machine.Outgoing())
+
+	machine.Transition("a")
+
+	assert.Empty(t, machine.Outgoing())
+}
+
 func TestWhenFnFailsTransitionFails(t *testing.T) {
 	builder := getMachineBuilder()
 
@@ -188,6 +263,25 @@ func TestWhenFnFailsTransitionFails(t *testing.T) {
 	}
 }
 
+func TestWhenFnFailsMachineStays(t *testing.T) {
+	builder := getMachineBuilder()
+
+	builder.Path("origin", "fails", "failing")
+	builder.Path("origin", "next", "end")
+	builder.Path("failing", "next", "end")
+
+	builder.WhenEntering("failing", func() error { return errors.New("Test error") })
+
+	machine := build(builder, "origin", t)
+
+	if err := machine.Transition("fails"); err == nil {
+		t.Fatal("Expected Transition() to fail, but it didn't")
+	}
+
+	assert.Equal(t, []string{"fails", "next"}, machine.Outgoing())
+	assert.Equal(t, "ORIGIN: origin >>fails>> failing", machine.DebugRoute())
+}
+
 func TestPaths(t *testing.T) {
 	builder := getMachineBuilder()
 
@@ -241,6 +335,100 @@ func TestPathsFailsIfTransitionExists(t *testing.T) {
 	assert.Equal(t, err.Error(), `Path "transition" already exists from "one"`)
 }
 
+func TestClone(t *testing.T) {
+	builder := getMachineBuilder()
+
+	builder.Path("origin", "next", "middle")
+	builder.Path("middle", "next", "end")
+	builder.Accept("end")
+
+	machine := build(builder, "origin", t)
+
+	machine.Transition("next")
+
+	clone := machine.Clone()
+
+	assert.Equal(t, "ORIGIN: origin", clone.DebugRoute())
+	assert.Error(t, clone.Finish())
+
+	clone.Transition("next")
+	clone.Transition("next")
+
+	assert.Nil(t, clone.Finish())
+	assert.Equal(t, "ORIGIN: origin >>next>> middle", machine.DebugRoute())
+	assert.Equal(t, []string{"next"}, machine.Outgoing())
+}
+
+func TestCloneFor(t *testing.T) {
+	entered := []string{}
+
+	builder := getMachineBuilder()
+
+	builder.Path("origin", "next", "end")
+	builder.WhenEnteringWith("end", dfa.Hook{Name: "enter", Call: func(subject interface{}) error {
+		entered = append(entered, subject.(string))
+
+		return nil
+	}})
+	builder.WhenTransitioningWith("next", dfa.Hook{Name: "next", Call: func(subject interface{}) error {
+		entered = append(entered, "via "+subject.(string))
+
+		return nil
+	}})
+
+	machine := build(builder, "origin", t)
+
+	machine.CloneFor("a").Transition("next")
+	machine.CloneFor("b").Clone().Transition("next")
+
+	assert.Equal(t, []string{"via a", "a", "via b", "b"}, entered)
+}
+
+func TestRouteIsBounded(t *testing.T) {
+	builder := getMachineBuilder()
+
+	builder.Path("one", "1", "two")
+	builder.Path("two", "2", "one")
+	builder.TraceRoute(3)
+
+	machine := build(builder, "one", t)
+
+	machine.Transition("1")
+	machine.Transition("2")
+
+	assert.Equal(t, "ORIGIN: one >>1>> two >>2>> one", machine.DebugRoute())
+
+	machine.Transition("1")
+	machine.Transition("2")
+	machine.Transition("1")
+
+	assert.Equal(t, "... one >>1>> two >>2>> one >>1>> two", machine.DebugRoute())
+
+	for i := 0; i < 1000; i++ {
+		machine.Transition("2")
+		machine.Transition("1")
+	}
+
+	assert.Equal(t, "... one >>1>> two >>2>> one >>1>> two", machine.DebugRoute())
+
+	machine.Reset()
+
+	assert.Equal(t, "ORIGIN: one", machine.DebugRoute())
+}
+
+func TestRouteNotTraced(t *testing.T) {
+	builder := getMachineBuilder()
+
+	builder.Path("one", "1", "two")
+	builder.TraceRoute(0)
+
+	machine := build(builder, "one", t)
+
+	machine.Transition("1")
+
+	assert.Equal(t, "", machine.DebugRoute())
+}
+
 func build(builder dfa.MachineBuilder, start string, t *testing.T) dfa.Machine {
 	machine, err := builder.Start(start)
 
@@ -254,3 +442,142 @@ func build(builder dfa.MachineBuilder, start string, t *testing.T) dfa.Machine {
 func getMachineBuilder() dfa.MachineBuilder {
 	return dfa.NewMachineBuilder()
 }
+
+func enterEnd() error {
+	return nil
+}
+
+func describedMachine(t *testing.T) dfa.Machine {
+	builder := getMachineBuilder()
+
+	builder.Path("origin", "a", "end")
+	builder.Path("origin", "b", "end")
+	builder.Path("origin", ";", "origin")
+	builder.Accept("end")
+	builder.WhenEntering("end", enterEnd)
+	builder.WhenTransitioningWith("b", dfa.Hook{Name: "via-b", Call: func(interface{}) error { return nil }})
+
+	return build(builder, "origin", t)
+}
+
+func TestDescribe(t *testing.T) {
+	machine := describedMachine(t)
+
+	machine.Transition("a")
+
+	assert.Equal(t, dfa.Description{
+		Start: "origin",
+		States: []dfa.StateDescription{
+			{Name: "end", Accepting: true, Paths: map[string]string{}, Hooks: []string{"enterEnd"}},
+			{Name: "origin", Paths: map[string]string{"a": "end", "b": "end", ";": "origin"}},
+		},
+		Transitions: map[string]string{"b": "via-b"},
+	}, machine.Describe())
+}
+
+func TestDOT(t *testing.T) {
+	assert.Equal(t, `digraph machine {
+	rankdir=LR;
+	node [shape=circle];
+	"" [shape=point];
+	"" -> "origin";
+	"end" [label="end\nenterEnd", shape=doublecircle];
+	"origin" [label="origin"];
+	"origin" -> "origin" [label=";"];
+	"origin" -> "end" [label="a\nb / via-b"];
+}
+`, describedMachine(t).Describe().DOT())
+}
+
+func TestMermaid(t *testing.T) {
+	assert.Equal(t, `stateDiagram-v2
+	s0 : end<br>enterEnd
+	s1 : origin
+	[*] --> s1
+	s1 --> s1 : #59;
+	s1 --> s0 : a<br>b / via-b
+	s0 --> [*]
+`, describedMachine(t).Describe().Mermaid())
+}
+
+func TestFuncName(t *testing.T) {
+	assert.Equal(t, "enterEnd", dfa.FuncName(enterEnd))
+	assert.Equal(t, "Describe", dfa.FuncName(dfa.Machine.Describe))
+}
+
+func TestValidate(t *testing.T) {
+	builder := getMachineBuilder()
+
+	builder.Path("origin", "a", "end")
+	builder.Path("origin", "a", "other")
+	builder.Path("origin", "b", "stuck")
+	builder.Path("lost", "c", "end")
+	builder.Accept("end")
+	builder.WhenEntering("missing", func() error { return nil })
+	builder.WhenTransitioningVia("d", func() error { return nil })
+
+	err := build(builder, "origin", t).Validate()
+
+	if assert.IsType(t, dfa.InvalidMachine{}, err) {
+		assert.Len(t, err.(dfa.InvalidMachine).Problems, 6)
+	}
+
+	assert.Equal(t, `Invalid machine:
+	Path "a" already exists from "origin"
+	Unknown machine state: missing
+	Machine state lost cannot be reached from the start
+	Machine state other cannot be reached from the start
+	No accepting state can be reached from machine state stuck
+	A function is called transitioning via d, but no path is via it`, err.Error())
+}
+
+func TestValidMachine(t *testing.T) {
+	assert.Nil(t, describedMachine(t).Validate())
+}
+
+type light int
+
+const (
+	red light = iota
+	green
+	amber
+)
+
+func (l light) String() string {
+	return [...]string{"red", "green", "amber"}[l]
+}
+
+type signal rune
+
+func TestTypedMachine(t *testing.T) {
+	builder := dfa.NewTypedMachineBuilder[light, signal]()
+	changes := 0
+
+	builder.Path(red, 'g', green)
+	builder.Path(green, 'a', amber)
+	builder.Path(green, 'b', amber)
+	builder.Path(amber, 'r', red)
+	builder.Accept(red)
+	builder.WhenTransitioningVia('r', func() error {
+		changes++
+
+		return nil
+	})
+
+	machine, err := builder.Start(red)
+
+	assert.Nil(t, err)
+	assert.Nil(t, machine.Validate())
+
+	assert.Nil(t, machine.Transition('g'))
+	assert.Equal(t, []signal{'a', 'b'}, machine.Outgoing())
+	assert.IsType(t, dfa.InvalidMachineTransition{}, machine.Transition('g'))
+	assert.Equal(t, "Don't know how to move from green to 103", machine.Transition('g').Error())
+	assert.Nil(t, machine.Transition('a'))
+	assert.Nil(t, machine.Transition('r'))
+	assert.Nil(t, machine.Finish())
+
+	assert.Equal(t, 1, changes)
+	assert.Equal(t, "ORIGIN: red >>103>> green >>97>> amber >>114>> red", machine.DebugRoute())
+	assert.Equal(t, "green", machine.Describe().States[1].Name)
+}
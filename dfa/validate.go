@@ -0,0 +1,158 @@
+package dfa
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Every problem found validating a machine.
+type InvalidMachine struct {
+	Problems []error
+}
+
+func (err InvalidMachine) Error() string {
+	problems := make([]string, len(err.Problems))
+
+	for i, problem := range err.Problems {
+		problems[i] = problem.Error()
+	}
+
+	return "Invalid machine:\n\t" + strings.Join(problems, "\n\t")
+}
+
+type UnreachableMachineState struct {
+	state string
+}
+
+func (err UnreachableMachineState) Error() string {
+	return fmt.Sprintf("Machine state %s cannot be reached from the start", err.state)
+}
+
+type DeadEndMachineState struct {
+	state string
+}
+
+func (err DeadEndMachineState) Error() string {
+	return fmt.Sprintf("No accepting state can be reached from machine state %s", err.state)
+}
+
+type UnusedMachineTransition struct {
+	how string
+}
+
+func (err UnusedMachineTransition) Error() string {
+	return fmt.Sprintf("A function is called transitioning via %s, but no path is via it", err.how)
+}
+
+// Reports the mistakes made building the machine, whether or
+// not they were ignored, then the states that can't be reached
+// or lead nowhere acceptable and the transition functions for
+// paths that don't exist.
+func (machine *machine[S, Y]) Validate() error {
+	problems := append([]error{}, machine.mistakes...)
+
+	reachable := machine.reachable()
+	accepting := machine.accepting()
+	used := make(map[Y]bool)
+
+	for _, name := range machine.stateNames() {
+		if !reachable[name] {
+			problems = append(problems, UnreachableMachineState{fmt.Sprint(name)})
+		} else if !accepting[name] {
+			problems = append(problems, DeadEndMachineState{fmt.Sprint(name)})
+		}
+
+		for how := range machine.states[name].paths {
+			used[how] = true
+		}
+	}
+
+	unused := []string{}
+
+	for how := range machine.transitions {
+		if !used[how] {
+			unused = append(unused, fmt.Sprint(how))
+		}
+	}
+
+	sort.Strings(unused)
+
+	for _, how := range unused {
+		problems = append(problems, UnusedMachineTransition{how})
+	}
+
+	if len(problems) > 0 {
+		return InvalidMachine{problems}
+	}
+
+	return nil
+}
+
+// Gets the names of the states, sorted by how they're printed.
+func (g *graph[S, Y]) stateNames() []S {
+	names := make([]S, 0, len(g.states))
+
+	for name := range g.states {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return fmt.Sprint(names[i]) < fmt.Sprint(names[j])
+	})
+
+	return names
+}
+
+// Gets the states that can be reached from the start.
+func (g *graph[S, Y]) reachable() map[S]bool {
+	reached := map[S]bool{g.start.name: true}
+	unvisited := []*state[S, Y]{g.start}
+
+	for len(unvisited) > 0 {
+		s := unvisited[len(unvisited)-1]
+		unvisited = unvisited[:len(unvisited)-1]
+
+		for _, to := range s.paths {
+			if !reached[to.name] {
+				reached[to.name] = true
+				unvisited = append(unvisited, to)
+			}
+		}
+	}
+
+	return reached
+}
+
+// Gets the states from which an acceptable state can be
+// reached, working back from the acceptable states.
+func (g *graph[S, Y]) accepting() map[S]bool {
+	from := make(map[S][]*state[S, Y])
+	accepting := make(map[S]bool)
+	unvisited := []*state[S, Y]{}
+
+	for _, s := range g.states {
+		for _, to := range s.paths {
+			from[to.name] = append(from[to.name], s)
+		}
+
+		if s.acceptable {
+			accepting[s.name] = true
+			unvisited = append(unvisited, s)
+		}
+	}
+
+	for len(unvisited) > 0 {
+		s := unvisited[len(unvisited)-1]
+		unvisited = unvisited[:len(unvisited)-1]
+
+		for _, previous := range from[s.name] {
+			if !accepting[previous.name] {
+				accepting[previous.name] = true
+				unvisited = append(unvisited, previous)
+			}
+		}
+	}
+
+	return accepting
+}
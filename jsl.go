@@ -14,68 +14,224 @@ import (
 
 	"io"
 
+	"io/ioutil"
+
 	"bufio"
 
 	"encoding/json"
 
+	"path/filepath"
+
+	"sort"
+
 	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/lint"
 	"github.com/ehimen/jaslang/parse"
 	"github.com/ehimen/jaslang/run"
+	"github.com/ehimen/jaslang/runtime"
 )
 
+var renderer = run.NewRenderer(0)
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		lintCode(os.Args[2:])
+
+		return
+	}
+
 	ast := flag.Bool("ast", false, "Prints the parsed AST as JSON. Does not execute code")
+	check := flag.Bool("check", false, "Checks identifiers and types. Does not execute code")
+	path := flag.String("path", "", "Directories to search for imported modules, separated by the OS path list separator")
+	context := flag.Int("context", 0, "Lines of source to show either side of an error")
+	vm := flag.Bool("vm", false, "Compiles code to bytecode and runs it on a virtual machine")
+	optimise := flag.Bool("optimise", false, "Folds constant expressions and removes dead code in the AST printed by -ast")
+	grammar := flag.String("grammar", "", `Prints the parser's state machine as "dot" or "mermaid". Does not execute code`)
 
 	flag.Parse()
 
-	file := flag.Arg(0)
+	renderer.Context = *context
 
-	var input io.RuneReader
+	if len(*grammar) > 0 {
+		printGrammar(*grammar)
 
-	if len(file) == 0 {
-		input = bufio.NewReader(os.Stdin)
+		return
+	}
+
+	file := flag.Arg(0)
+	input := open(file)
+
+	if *ast {
+		printAst(input, file, *optimise)
+	} else if *check {
+		checkTypes(input, file)
 	} else {
-		if f, err := os.Open(file); err != nil {
+		execute(input, file, filepath.SplitList(*path), *vm)
+	}
+}
+
+// Opens file, or stdin if no file is given.
+func open(file string) io.RuneReader {
+	if len(file) > 0 {
+		f, err := os.Open(file)
+
+		if err != nil {
 			log.Fatal(err)
-		} else {
-			input = bufio.NewReader(f)
 		}
+
+		return bufio.NewReader(f)
 	}
 
-	if *ast {
-		printAst(input)
-	} else {
-		execute(input)
+	// Buffer stdin so errors can be shown with their source.
+	code, err := ioutil.ReadAll(os.Stdin)
+
+	if err != nil {
+		log.Fatal(err)
 	}
+
+	renderer.AddSource("", string(code))
+
+	return strings.NewReader(string(code))
 }
 
-func execute(file io.RuneReader) {
+func execute(code io.RuneReader, file string, searchPath []string, compiled bool) {
 	input := strings.NewReader("")
 	output := bytes.NewBufferString("")
 	outputError := bytes.NewBufferString("")
 
-	if run.Interpret(file, input, output, outputError) {
-		fail(outputError.String())
+	var err error
+
+	if len(file) == 0 && compiled {
+		err = run.ExecuteCompiled(code, input, output, outputError)
+	} else if len(file) == 0 {
+		err = run.Execute(code, input, output, outputError)
+	} else if compiled {
+		err = run.ExecuteFileCompiled(file, searchPath, input, output, outputError)
+	} else {
+		err = run.ExecuteFile(file, searchPath, input, output, outputError)
+	}
+
+	fmt.Fprint(os.Stderr, outputError.String())
+
+	if err != nil {
+		fail(err)
 	} else {
 		fmt.Println(output.String())
 	}
 }
 
-func printAst(file io.RuneReader) {
-	parser := parse.NewParser(lex.NewJslLexer(file))
+// Prints the AST, including what could be parsed
+// of it if there are syntax errors.
+func printAst(code io.RuneReader, file string, optimise bool) {
+	parser := parse.NewRecoveringParser(lex.NewJslFileLexer(file, code))
+
+	ast, parseErr := parser.Parse()
+
+	for _, warning := range parser.Warnings() {
+		fmt.Fprintln(os.Stderr, "Warning: "+warning.String())
+	}
+
+	var printed parse.Node = ast
+
+	if optimise {
+		printed = runtime.Optimise(ast, runtime.NewBuiltinTable())
+	}
 
-	if ast, err := parser.Parse(); err != nil {
-		fail(err.Error())
+	if astJson, err := json.MarshalIndent(printed, "", "    "); err != nil {
+		fail(err)
 	} else {
-		if astJson, err := json.MarshalIndent(ast, "", "    "); err != nil {
-			fail(err.Error())
+		fmt.Println(string(astJson))
+	}
+
+	if parseErr != nil {
+		fail(parseErr)
+	}
+}
+
+// Prints the parser's grammar as a state graph, so
+// changes to it can be reviewed as a picture.
+func printGrammar(format string) {
+	switch format {
+	case "dot":
+		fmt.Print(parse.Grammar().DOT())
+	case "mermaid":
+		fmt.Print(parse.Grammar().Mermaid())
+	default:
+		log.Fatalf(`Unknown grammar format "%s", expected "dot" or "mermaid"`, format)
+	}
+}
+
+// Reports all problems found by parsing and checking
+// the code, without executing it.
+func checkTypes(code io.RuneReader, file string) {
+	parser := parse.NewRecoveringParser(lex.NewJslFileLexer(file, code))
+
+	ast, err := parser.Parse()
+
+	for _, warning := range parser.Warnings() {
+		fmt.Fprintln(os.Stderr, "Warning: "+warning.String())
+	}
+
+	if err != nil {
+		fail(err)
+	}
+
+	if err := runtime.Check(ast, runtime.NewBuiltinTable()); err != nil {
+		fail(err)
+	}
+}
+
+// Implements "jsl lint [-json] [file]", printing warnings about
+// code that's probably not what was meant. Exits with status 1
+// if there are any.
+func lintCode(args []string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	asJson := flags.Bool("json", false, "Prints warnings as a JSON array")
+	context := flags.Int("context", 0, "Lines of source to show either side of a warning")
+
+	flags.Parse(args)
+
+	renderer.Context = *context
+
+	parser := parse.NewRecoveringParser(lex.NewJslFileLexer(flags.Arg(0), open(flags.Arg(0))))
+
+	ast, err := parser.Parse()
+
+	if err != nil {
+		fail(err)
+	}
+
+	warnings := lex.Diagnostics{}
+
+	for _, warning := range parser.Warnings() {
+		warnings = append(warnings, warning.Diagnostic())
+	}
+
+	warnings = append(lint.Suppress(warnings, parser.Comments()), lint.Lint(ast, parser.Comments())...)
+
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return warnings[i].Start.Line < warnings[j].Start.Line
+	})
+
+	if *asJson {
+		if warningsJson, err := json.MarshalIndent(warnings, "", "    "); err != nil {
+			fail(err)
 		} else {
+			fmt.Println(string(warningsJson))
+		}
+	} else {
+		for _, warning := range warnings {
+			warning.Message = fmt.Sprintf("warning: %s (%s)", warning.Message, warning.Code)
 
-			fmt.Println(string(astJson))
+			fmt.Println(renderer.Render(warning))
 		}
 	}
+
+	if len(warnings) > 0 {
+		os.Exit(1)
+	}
 }
 
-func fail(msg string) {
-	log.Fatal(fmt.Sprintf("%s\n", msg))
+func fail(err error) {
+	log.Fatal(fmt.Sprintf("%s\n", renderer.Render(err)))
 }
@@ -0,0 +1,233 @@
+package lex
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// How serious a diagnostic is.
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityNote
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityNote:
+		return "note"
+	}
+
+	return "error"
+}
+
+// A stretch of source, from Start up to but not including End.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// Gets the span of length columns from start.
+func SpanAt(start Position, length int) Span {
+	end := start
+
+	if start.Line > 0 {
+		end.Column += length
+	}
+
+	return Span{Start: start, End: end}
+}
+
+// Extra information attached to a diagnostic,
+// optionally pointing at another part of the source.
+type Note struct {
+	Message string
+	Span    Span
+}
+
+func (n Note) String() string {
+	if n.Span.Start.Line == 0 {
+		return n.Message
+	}
+
+	return fmt.Sprintf("%s: %s", n.Span.Start, n.Message)
+}
+
+// A problem found in the source by any stage: lexing, parsing
+// or evaluation. Code identifies the kind of problem, e.g.
+// "unexpected-token", and is stable for tooling to match on.
+// Err is the error that was diagnosed, if any.
+type Diagnostic struct {
+	Severity Severity
+	Code     string
+	Message  string
+	Span
+	Notes []Note
+	Err   error
+}
+
+func (d Diagnostic) Error() string {
+	lines := []string{d.Message}
+
+	if d.Start.Line > 0 {
+		lines[0] = fmt.Sprintf("%s: %s", d.Start, d.Message)
+	}
+
+	for _, note := range d.Notes {
+		lines = append(lines, note.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Gives the diagnostic for tooling, without the error
+// it was diagnosed from.
+func (d Diagnostic) MarshalJSON() ([]byte, error) {
+	type located struct {
+		Message string
+		Start   Position
+		End     Position
+	}
+
+	notes := []located{}
+
+	for _, note := range d.Notes {
+		notes = append(notes, located{note.Message, note.Span.Start, note.Span.End})
+	}
+
+	return json.Marshal(struct {
+		Severity string
+		Code     string
+		Message  string
+		Start    Position
+		End      Position
+		Notes    []located
+	}{
+		Severity: d.Severity.String(),
+		Code:     d.Code,
+		Message:  d.Message,
+		Start:    d.Start,
+		End:      d.End,
+		Notes:    notes,
+	})
+}
+
+func (d Diagnostic) Unwrap() error {
+	return d.Err
+}
+
+func (d Diagnostic) Location() Position {
+	return d.Start
+}
+
+// Gets the number of columns the diagnostic covers on its
+// first line, which is zero if it isn't located.
+func (d Diagnostic) Length() int {
+	if d.End.Line != d.Start.Line || d.End.Column < d.Start.Column {
+		return 0
+	}
+
+	return d.End.Column - d.Start.Column
+}
+
+// Several diagnostics, reported together as one error.
+type Diagnostics []Diagnostic
+
+func (diagnostics Diagnostics) Error() string {
+	messages := []string{}
+
+	for _, diagnostic := range diagnostics {
+		messages = append(messages, diagnostic.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// Gets the location of the first diagnostic.
+func (diagnostics Diagnostics) Location() Position {
+	if len(diagnostics) == 0 {
+		return Position{}
+	}
+
+	return diagnostics[0].Start
+}
+
+func (diagnostics Diagnostics) Unwrap() []error {
+	unwrapped := []error{}
+
+	for _, diagnostic := range diagnostics {
+		unwrapped = append(unwrapped, diagnostic)
+	}
+
+	return unwrapped
+}
+
+// Implemented by errors that can describe themselves as a
+// diagnostic. They needn't set Err; Diagnose does that.
+type Diagnosable interface {
+	Diagnostic() Diagnostic
+}
+
+// An error that knows nothing more about itself than
+// its diagnostic code and message.
+type CodedError struct {
+	Code    string
+	Message string
+}
+
+func (err CodedError) Error() string {
+	return err.Message
+}
+
+func (err CodedError) Diagnostic() Diagnostic {
+	return Diagnostic{Severity: SeverityError, Code: err.Code, Message: err.Message}
+}
+
+// Describes err as a diagnostic. Errors that can't describe
+// themselves are given the code "error". Where err doesn't
+// know where it was raised, the position it was given with
+// At is used.
+func Diagnose(err error) Diagnostic {
+	if diagnostic, isDiagnostic := err.(Diagnostic); isDiagnostic {
+		return diagnostic
+	}
+
+	var diagnostic Diagnostic
+	var diagnosable Diagnosable
+	var positioned Error
+
+	if errors.As(err, &diagnostic) {
+		// Already diagnosed, but since positioned.
+	} else if errors.As(err, &diagnosable) {
+		diagnostic = diagnosable.Diagnostic()
+	} else {
+		diagnostic = Diagnostic{Severity: SeverityError, Code: "error", Message: err.Error()}
+
+		if errors.As(err, &positioned) {
+			diagnostic.Message = positioned.Err.Error()
+		}
+	}
+
+	if diagnostic.Start.Line == 0 && errors.As(err, &positioned) {
+		diagnostic.Span = SpanAt(positioned.Position, 1)
+	}
+
+	diagnostic.Err = err
+
+	return diagnostic
+}
+
+// Diagnoses err if it isn't nil.
+func Diagnosed(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return Diagnose(err)
+}
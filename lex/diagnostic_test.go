@@ -0,0 +1,52 @@
+package lex_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiagnoseCodedError(t *testing.T) {
+	err := lex.At(lex.CodedError{Code: "test", Message: "Broken"}, lex.Position{File: "a.jsl", Line: 2, Column: 4})
+
+	diagnostic := lex.Diagnose(err)
+
+	assert.Equal(t, "test", diagnostic.Code)
+	assert.Equal(t, "Broken", diagnostic.Message)
+	assert.Equal(t, lex.SpanAt(lex.Position{File: "a.jsl", Line: 2, Column: 4}, 1), diagnostic.Span)
+	assert.Equal(t, "a.jsl:2:4: Broken", diagnostic.Error())
+	assert.Equal(t, err, diagnostic.Unwrap())
+}
+
+func TestDiagnosePlainError(t *testing.T) {
+	diagnostic := lex.Diagnose(errors.New("Broken"))
+
+	assert.Equal(t, lex.SeverityError, diagnostic.Severity)
+	assert.Equal(t, "error", diagnostic.Code)
+	assert.Equal(t, "Broken", diagnostic.Error())
+	assert.Equal(t, 0, diagnostic.Length())
+}
+
+func TestDiagnoseIsIdempotent(t *testing.T) {
+	diagnostic := lex.Diagnose(lex.UnterminatedString)
+
+	assert.Equal(t, diagnostic, lex.Diagnose(diagnostic))
+	assert.True(t, errors.Is(diagnostic, lex.UnterminatedString))
+	assert.Nil(t, lex.Diagnosed(nil))
+}
+
+func TestDiagnosticNotes(t *testing.T) {
+	diagnostic := lex.Diagnostic{
+		Message: "Broken",
+		Span:    lex.SpanAt(lex.Position{Line: 3, Column: 2}, 5),
+		Notes: []lex.Note{
+			{Message: "Started here", Span: lex.SpanAt(lex.Position{Line: 1, Column: 1}, 1)},
+			{Message: "Try fixing it"},
+		},
+	}
+
+	assert.Equal(t, 5, diagnostic.Length())
+	assert.Equal(t, "3:2: Broken\n1:1: Started here\nTry fixing it", diagnostic.Error())
+}
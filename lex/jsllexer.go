@@ -20,6 +20,7 @@ func (pos *runePosition) inc(char string) {
 }
 
 type jslLexer struct {
+	file        string
 	reader      io.RuneReader
 	ch          chan Lexeme
 	position    runePosition
@@ -29,10 +30,20 @@ type jslLexer struct {
 	err         error
 	lookahead   []string
 	initialised bool
+	// Whether anything but whitespace and comments
+	// has been emitted since the last new line.
+	codeOnLine bool
 }
 
 func NewJslLexer(reader io.RuneReader) Lexer {
+	return NewJslFileLexer("", reader)
+}
+
+// Creates a lexer for the code in reader, which was read
+// from file. Lexemes and errors are positioned in file.
+func NewJslFileLexer(file string, reader io.RuneReader) Lexer {
 	return &jslLexer{
+		file:        file,
 		reader:      reader,
 		ch:          make(chan Lexeme, 1),
 		position:    runePosition{1, 1},
@@ -60,6 +71,10 @@ func (l *jslLexer) GetNext() (Lexeme, error) {
 			l.start = l.position
 
 			l.fn, l.err = l.fn(l)
+
+			if l.err != nil && l.err != EndOfInput {
+				l.err = Diagnose(At(l.err, Position{File: l.file, Line: l.start.line, Column: l.start.column}))
+			}
 		}
 	}
 }
@@ -134,11 +149,28 @@ func (l *jslLexer) emit(lexemeType LexemeType) {
 		return
 	}
 
+	switch lexemeType {
+	case LWhitespace:
+		if strings.Contains(l.current, "\n") {
+			l.codeOnLine = false
+		}
+	case LComment:
+		if l.codeOnLine {
+			lexemeType = LTrailingComment
+		}
+	default:
+		l.codeOnLine = true
+	}
+
 	l.ch <- Lexeme{
 		Start: l.start.column,
 		Type:  lexemeType,
 		Value: l.current,
 		Line:  l.start.line,
+		File:  l.file,
+
+		EndLine:   l.position.line,
+		EndColumn: l.position.column,
 	}
 
 	l.current = ""
@@ -188,6 +220,8 @@ func defaultState(l *jslLexer) (stateFunction, error) {
 		return spaceState, nil
 	case isSpecialSymbol(next):
 		return characterState, nil
+	case next == "/" && l.peekAhead(2) == "/":
+		return commentState, nil
 	case strings.IndexAny(next, "+-0123456789") == 0:
 		return numberState, nil
 	case isOperatorCharacter(next):
@@ -308,6 +342,22 @@ func spaceState(l *jslLexer) (stateFunction, error) {
 	return nil, EndOfInput
 }
 
+// Lexes a comment, from // to the end of the line.
+func commentState(l *jslLexer) (stateFunction, error) {
+	for l.has() {
+		if l.peek() == "\n" {
+			l.emit(LComment)
+			return defaultState, nil
+		}
+
+		l.next()
+	}
+
+	l.emit(LComment)
+
+	return nil, EndOfInput
+}
+
 func identifierState(l *jslLexer) (stateFunction, error) {
 	for l.has() {
 		next := l.peek()
@@ -1,11 +1,13 @@
 package lex_test
 
 import (
+	"errors"
 	"strings"
 	"testing"
 
 	"github.com/ehimen/jaslang/lex"
 	"github.com/ehimen/jaslang/testutil"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestNewJslLexer(t *testing.T) {
@@ -30,7 +32,7 @@ func TestIdentifierAndString(t *testing.T) {
 		"foo\"bar\"",
 		[]lex.Lexeme{
 			testutil.MakeLexeme("foo", lex.LIdentifier, 1, 1),
-			testutil.MakeLexeme("bar", lex.LQuoted, 4, 1),
+			testutil.MakeSourceLexeme("bar", lex.LQuoted, 4, 1, 9, 1),
 		},
 	)
 }
@@ -42,7 +44,7 @@ func TestIdentWsString(t *testing.T) {
 		[]lex.Lexeme{
 			testutil.MakeLexeme("foo", lex.LIdentifier, 1, 1),
 			testutil.MakeLexeme(" ", lex.LWhitespace, 4, 1),
-			testutil.MakeLexeme("bar", lex.LQuoted, 5, 1),
+			testutil.MakeSourceLexeme("bar", lex.LQuoted, 5, 1, 10, 1),
 		},
 	)
 }
@@ -52,7 +54,7 @@ func TestOtherQuotedString(t *testing.T) {
 		t,
 		`'bar "foo"'`,
 		[]lex.Lexeme{
-			testutil.MakeLexeme(`bar "foo"`, lex.LQuoted, 1, 1),
+			testutil.MakeSourceLexeme(`bar "foo"`, lex.LQuoted, 1, 1, 12, 1),
 		},
 	)
 }
@@ -62,7 +64,7 @@ func TestOtherOtherQuotedString(t *testing.T) {
 		t,
 		`"bar 'foo'"`,
 		[]lex.Lexeme{
-			testutil.MakeLexeme("bar 'foo'", lex.LQuoted, 1, 1),
+			testutil.MakeSourceLexeme("bar 'foo'", lex.LQuoted, 1, 1, 12, 1),
 		},
 	)
 }
@@ -72,7 +74,7 @@ func TestEscapedQuote(t *testing.T) {
 		t,
 		`"\""`,
 		[]lex.Lexeme{
-			testutil.MakeLexeme(`"`, lex.LQuoted, 1, 1),
+			testutil.MakeSourceLexeme(`"`, lex.LQuoted, 1, 1, 5, 1),
 		},
 	)
 }
@@ -82,7 +84,7 @@ func TestEscapedBackslash(t *testing.T) {
 		t,
 		`"\\"`,
 		[]lex.Lexeme{
-			testutil.MakeLexeme(`\`, lex.LQuoted, 1, 1),
+			testutil.MakeSourceLexeme(`\`, lex.LQuoted, 1, 1, 5, 1),
 		},
 	)
 }
@@ -92,7 +94,7 @@ func TestEscapedOtherQuote(t *testing.T) {
 		t,
 		`"\'"`,
 		[]lex.Lexeme{
-			testutil.MakeLexeme(`\'`, lex.LQuoted, 1, 1),
+			testutil.MakeSourceLexeme(`\'`, lex.LQuoted, 1, 1, 5, 1),
 		},
 	)
 }
@@ -102,7 +104,7 @@ func TestBackslashesAndEscapedQuotes(t *testing.T) {
 		t,
 		`"\\\""`,
 		[]lex.Lexeme{
-			testutil.MakeLexeme(`\"`, lex.LQuoted, 1, 1),
+			testutil.MakeSourceLexeme(`\"`, lex.LQuoted, 1, 1, 7, 1),
 		},
 	)
 }
@@ -111,11 +113,71 @@ func TestUnterminatedString(t *testing.T) {
 	lexer := makeLexer(`"foo`)
 	_, err := lexer.GetNext()
 
-	if err != lex.UnterminatedString {
+	if !errors.Is(err, lex.UnterminatedString) {
 		t.Errorf("Expected unterminated string, but got %v", err)
 	}
 }
 
+func TestComment(t *testing.T) {
+	doTestGetNext(
+		t,
+		"a / b // a / b\n// end",
+		[]lex.Lexeme{
+			testutil.MakeLexeme("a", lex.LIdentifier, 1, 1),
+			testutil.MakeLexeme(" ", lex.LWhitespace, 2, 1),
+			testutil.MakeLexeme("/", lex.LOperator, 3, 1),
+			testutil.MakeLexeme(" ", lex.LWhitespace, 4, 1),
+			testutil.MakeLexeme("b", lex.LIdentifier, 5, 1),
+			testutil.MakeLexeme(" ", lex.LWhitespace, 6, 1),
+			testutil.MakeLexeme("// a / b", lex.LTrailingComment, 7, 1),
+			testutil.MakeLexeme("\n", lex.LWhitespace, 15, 1),
+			testutil.MakeLexeme("// end", lex.LComment, 1, 2),
+		},
+	)
+}
+
+func TestErrorPosition(t *testing.T) {
+	lexer := lex.NewJslFileLexer("test.jsl", getReader("foo\n  \"bar"))
+
+	for {
+		if _, err := lexer.GetNext(); err != nil {
+			if err.Error() != "test.jsl:2:3: Unterminated string" {
+				t.Errorf("Expected positioned unterminated string, but got %v", err)
+			}
+
+			break
+		}
+	}
+}
+
+func TestErrorDiagnostic(t *testing.T) {
+	lexer := lex.NewJslFileLexer("test.jsl", getReader("foo\n  \"bar"))
+
+	for {
+		if _, err := lexer.GetNext(); err != nil {
+			var diagnostic lex.Diagnostic
+
+			if !errors.As(err, &diagnostic) {
+				t.Fatalf("Expected diagnostic, but got %v", err)
+			}
+
+			assert.Equal(t, lex.SeverityError, diagnostic.Severity)
+			assert.Equal(t, "unterminated-string", diagnostic.Code)
+			assert.Equal(t, "Unterminated string", diagnostic.Message)
+			assert.Equal(t, lex.Position{File: "test.jsl", Line: 2, Column: 3}, diagnostic.Start)
+
+			break
+		}
+	}
+}
+
+func TestFileLexemes(t *testing.T) {
+	lexeme := testutil.MakeLexeme("foo", lex.LIdentifier, 1, 1)
+	lexeme.File = "test.jsl"
+
+	assertLexemes(t, lex.NewJslFileLexer("test.jsl", getReader("foo")), []lex.Lexeme{lexeme})
+}
+
 func TestCharacterSymbols(t *testing.T) {
 	doTestGetNext(
 		t,
@@ -279,6 +341,74 @@ func TestWhile(t *testing.T) {
 	)
 }
 
+func TestMatch(t *testing.T) {
+	doTestGetNext(
+		t,
+		"match(a){1=>{}_=>{}}",
+		[]lex.Lexeme{
+			testutil.MakeLexeme("match", lex.LMatch, 1, 1),
+			testutil.MakeLexeme("(", lex.LParenOpen, 6, 1),
+			testutil.MakeLexeme("a", lex.LIdentifier, 7, 1),
+			testutil.MakeLexeme(")", lex.LParenClose, 8, 1),
+			testutil.MakeLexeme("{", lex.LBraceOpen, 9, 1),
+			testutil.MakeLexeme("1", lex.LNumber, 10, 1),
+			testutil.MakeLexeme("=>", lex.LOperator, 11, 1),
+			testutil.MakeLexeme("{", lex.LBraceOpen, 13, 1),
+			testutil.MakeLexeme("}", lex.LBraceClose, 14, 1),
+			testutil.MakeLexeme("_", lex.LIdentifier, 15, 1),
+			testutil.MakeLexeme("=>", lex.LOperator, 16, 1),
+			testutil.MakeLexeme("{", lex.LBraceOpen, 18, 1),
+			testutil.MakeLexeme("}", lex.LBraceClose, 19, 1),
+			testutil.MakeLexeme("}", lex.LBraceClose, 20, 1),
+		},
+	)
+}
+
+func TestTryCatch(t *testing.T) {
+	doTestGetNext(
+		t,
+		"try{throw e;}catch(e){}finally{}",
+		[]lex.Lexeme{
+			testutil.MakeLexeme("try", lex.LTry, 1, 1),
+			testutil.MakeLexeme("{", lex.LBraceOpen, 4, 1),
+			testutil.MakeLexeme("throw", lex.LThrow, 5, 1),
+			testutil.MakeLexeme(" ", lex.LWhitespace, 10, 1),
+			testutil.MakeLexeme("e", lex.LIdentifier, 11, 1),
+			testutil.MakeLexeme(";", lex.LSemiColon, 12, 1),
+			testutil.MakeLexeme("}", lex.LBraceClose, 13, 1),
+			testutil.MakeLexeme("catch", lex.LCatch, 14, 1),
+			testutil.MakeLexeme("(", lex.LParenOpen, 19, 1),
+			testutil.MakeLexeme("e", lex.LIdentifier, 20, 1),
+			testutil.MakeLexeme(")", lex.LParenClose, 21, 1),
+			testutil.MakeLexeme("{", lex.LBraceOpen, 22, 1),
+			testutil.MakeLexeme("}", lex.LBraceClose, 23, 1),
+			testutil.MakeLexeme("finally", lex.LFinally, 24, 1),
+			testutil.MakeLexeme("{", lex.LBraceOpen, 31, 1),
+			testutil.MakeLexeme("}", lex.LBraceClose, 32, 1),
+		},
+	)
+}
+
+func TestImportExport(t *testing.T) {
+	doTestGetNext(
+		t,
+		`import "lib.jsl" as lib;export let`,
+		[]lex.Lexeme{
+			testutil.MakeLexeme("import", lex.LImport, 1, 1),
+			testutil.MakeLexeme(" ", lex.LWhitespace, 7, 1),
+			testutil.MakeSourceLexeme("lib.jsl", lex.LQuoted, 8, 1, 17, 1),
+			testutil.MakeLexeme(" ", lex.LWhitespace, 17, 1),
+			testutil.MakeLexeme("as", lex.LAs, 18, 1),
+			testutil.MakeLexeme(" ", lex.LWhitespace, 20, 1),
+			testutil.MakeLexeme("lib", lex.LIdentifier, 21, 1),
+			testutil.MakeLexeme(";", lex.LSemiColon, 24, 1),
+			testutil.MakeLexeme("export", lex.LExport, 25, 1),
+			testutil.MakeLexeme(" ", lex.LWhitespace, 31, 1),
+			testutil.MakeLexeme("let", lex.LLet, 32, 1),
+		},
+	)
+}
+
 func TestBoolValues(t *testing.T) {
 	doTestGetNext(
 		t,
@@ -408,10 +538,10 @@ func TestArgumentList(t *testing.T) {
 		[]lex.Lexeme{
 			testutil.MakeLexeme("println", lex.LIdentifier, 1, 1),
 			testutil.MakeLexeme("(", lex.LParenOpen, 8, 1),
-			testutil.MakeLexeme("hello", lex.LQuoted, 9, 1),
+			testutil.MakeSourceLexeme("hello", lex.LQuoted, 9, 1, 16, 1),
 			testutil.MakeLexeme(",", lex.LComma, 16, 1),
 			testutil.MakeLexeme(" ", lex.LWhitespace, 17, 1),
-			testutil.MakeLexeme("world", lex.LQuoted, 18, 1),
+			testutil.MakeSourceLexeme("world", lex.LQuoted, 18, 1, 25, 1),
 			testutil.MakeLexeme(")", lex.LParenClose, 25, 1),
 		},
 	)
@@ -3,6 +3,7 @@ package lex
 import (
 	"errors"
 	"fmt"
+	"unicode/utf8"
 )
 
 type LexemeType string
@@ -31,26 +32,57 @@ const (
 	LElseIf     LexemeType = "elseif"
 	LWhile      LexemeType = "while"
 	LLet        LexemeType = "let"
+	LMatch      LexemeType = "match"
+	LThrow      LexemeType = "throw"
+	LTry        LexemeType = "try"
+	LCatch      LexemeType = "catch"
+	LFinally    LexemeType = "finally"
+	LImport     LexemeType = "import"
+	LAs         LexemeType = "as"
+	LExport     LexemeType = "export"
 	LBoolTrue   LexemeType = "true"
 	LBoolFalse  LexemeType = "false"
 	LEquals     LexemeType = "="
 	LComma      LexemeType = ","
+	LComment    LexemeType = "comment"
+	// A comment following code on the same line.
+	LTrailingComment LexemeType = "trailing-comment"
 
 	OperatorSymbols   string = "+-.^*&/|=><!"
 	SpecialCharacters string = "{}();,"
 )
 
-var Keywords = []LexemeType{LIf, LElse, LElseIf, LLet, LWhile}
+var Keywords = []LexemeType{LIf, LElse, LElseIf, LLet, LWhile, LMatch, LThrow, LTry, LCatch, LFinally, LImport, LAs, LExport}
 
 type Lexeme struct {
 	Start int
 	Line  int
 	Type  LexemeType
 	Value string
+	File  string
+	// Where the lexeme's source ends, just after its last
+	// rune. Value may be shorter, such as a quoted string's
+	// without its quotes.
+	EndLine   int
+	EndColumn int
 }
 
 func (l Lexeme) String() string {
-	return fmt.Sprintf("\"%s\" (%s) at position %d:%d", l.Value, l.Type, l.Line, l.Start)
+	return fmt.Sprintf("\"%s\" (%s) at %s", l.Value, l.Type, l.Position())
+}
+
+func (l Lexeme) Position() Position {
+	return Position{File: l.File, Line: l.Line, Column: l.Start}
+}
+
+// Gets the span of the lexeme's source, or of its
+// value if where its source ends isn't known.
+func (l Lexeme) Span() Span {
+	if l.EndLine == 0 {
+		return SpanAt(l.Position(), utf8.RuneCountInString(l.Value))
+	}
+
+	return Span{Start: l.Position(), End: Position{File: l.File, Line: l.EndLine, Column: l.EndColumn}}
 }
 
 func (l Lexeme) IsEmpty() bool {
@@ -62,10 +94,64 @@ type Lexer interface {
 }
 
 var EndOfInput = errors.New("End of Input")
-var UnterminatedString = errors.New("Unterminated string")
+var UnterminatedString = CodedError{Code: "unterminated-string", Message: "Unterminated string"}
 
 type UnexpectedToken error
 
 func NewUnexpectedToken(token string) UnexpectedToken {
-	return errors.New(fmt.Sprintf("Unexpected token: %s", token))
+	return CodedError{Code: "unexpected-character", Message: fmt.Sprintf("Unexpected token: %s", token)}
+}
+
+// A location in source code. File is empty
+// when the source doesn't have a name.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// Formats the position as it prefixes error
+// messages, e.g. file.jsl:3:7.
+func (p Position) String() string {
+	if len(p.File) == 0 {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// Implemented by errors that know where in
+// the source they were raised.
+type Located interface {
+	error
+	Location() Position
+}
+
+// Gives an error that doesn't know where it
+// was raised a position in the source.
+type Error struct {
+	Err error
+	Position
+}
+
+func (err Error) Error() string {
+	return fmt.Sprintf("%s: %s", err.Position, err.Err)
+}
+
+func (err Error) Unwrap() error {
+	return err.Err
+}
+
+func (err Error) Location() Position {
+	return err.Position
+}
+
+// Locates err at position, unless it already
+// knows where it was raised.
+func At(err error, position Position) error {
+	if located, isLocated := err.(Located); isLocated && located.Location().Line > 0 {
+		return err
+	}
+
+	return Error{Err: err, Position: position}
 }
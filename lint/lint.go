@@ -0,0 +1,382 @@
+// Package lint finds code that is valid, but probably not
+// what was meant: variables that are never read, values
+// that are never used and code that never runs.
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/parse"
+)
+
+// Suppresses warnings on its own line and the next, e.g.
+// "// lint:ignore unused-variable, shadowed-variable".
+// Without codes, all warnings are suppressed.
+var suppression = regexp.MustCompile(`^//\s*lint:ignore\b\s*(.*)$`)
+
+// A value written to a variable that hasn't yet been read.
+type store struct {
+	node   *parse.Identifier
+	blocks []parse.ContainsChildren
+}
+
+type variable struct {
+	declaration *parse.Identifier
+	exported    bool
+	read        bool
+	pending     *store
+	dead        []*store
+}
+
+type linter struct {
+	variables map[string]*variable
+	order     []*variable
+	blocks    []parse.ContainsChildren
+	warnings  lex.Diagnostics
+}
+
+// Lints root, giving a warning for each problem found that
+// isn't suppressed by one of comments.
+func Lint(root parse.RootNode, comments []lex.Lexeme) lex.Diagnostics {
+	l := &linter{variables: make(map[string]*variable)}
+
+	statements := []parse.Node{}
+
+	for _, statement := range root.Statements {
+		statements = append(statements, statement)
+	}
+
+	l.lintBlock(statements)
+
+	for _, v := range l.order {
+		if !v.read && !v.exported {
+			l.warn("unused-variable", fmt.Sprintf("%s is declared but never read", v.declaration.Identifier), v.declaration)
+
+			continue
+		}
+
+		for _, dead := range v.dead {
+			l.warn("unused-assignment", fmt.Sprintf("Value assigned to %s is never read", dead.node.Identifier), dead.node)
+		}
+
+		if v.pending != nil && !v.exported {
+			l.warn("unused-assignment", fmt.Sprintf("Value assigned to %s is never read", v.pending.node.Identifier), v.pending.node)
+		}
+	}
+
+	sort.SliceStable(l.warnings, func(i, j int) bool {
+		return before(l.warnings[i].Start, l.warnings[j].Start)
+	})
+
+	return Suppress(l.warnings, comments)
+}
+
+func before(a lex.Position, b lex.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+func (l *linter) warn(code string, message string, node parse.Node) {
+	length := 1
+
+	if identifier, isIdentifier := node.(*parse.Identifier); isIdentifier {
+		length = utf8.RuneCountInString(identifier.Identifier)
+	}
+
+	l.warnings = append(l.warnings, lex.Diagnostic{
+		Severity: lex.SeverityWarning,
+		Code:     code,
+		Message:  message,
+		Span:     lex.SpanAt(parse.Position(node), length),
+	})
+}
+
+// Lints statements in order, warning about any that
+// follow a throw, as they can never run.
+func (l *linter) lintBlock(statements []parse.Node) {
+	for i, statement := range statements {
+		l.lint(statement)
+
+		if throws(statement) && i < len(statements)-1 {
+			l.warn("unreachable-code", "Code after throw is never run", statements[i+1])
+
+			for _, unreachable := range statements[i+1:] {
+				l.lint(unreachable)
+			}
+
+			return
+		}
+	}
+}
+
+func (l *linter) lint(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.Identifier:
+		l.read(n.Identifier)
+	case *parse.Operator:
+		if n.Operator == "." {
+			// The member isn't a variable, only what it's a member of.
+			if children := n.Children(); len(children) > 0 {
+				l.lint(children[0])
+			}
+
+			return
+		}
+
+		l.lintChildren(n)
+	case *parse.Let:
+		l.lintChildren(n)
+		l.declare(n.Identifier, false)
+
+		if len(n.Children()) > 0 {
+			l.write(n.Identifier)
+		}
+	case *parse.Export:
+		for _, child := range n.Children() {
+			l.lint(child)
+
+			if let, isLet := child.(*parse.Let); isLet {
+				l.variables[let.Identifier.Identifier].exported = true
+			}
+		}
+	case *parse.Assignment:
+		l.lintChildren(n)
+		l.write(n.Identifier)
+	case *parse.Import:
+		l.declare(n.Alias, false)
+	case *parse.If:
+		l.lintIf(n)
+	case *parse.Match:
+		l.lint(n.Subject())
+
+		for _, arm := range n.Arms() {
+			for _, value := range arm.Values() {
+				l.lint(value)
+			}
+
+			l.lintNested(arm)
+		}
+	case *parse.Try:
+		l.lintNested(n)
+
+		if catch := n.Catch(); catch != nil {
+			if _, declared := l.variables[catch.Identifier.Identifier]; !declared {
+				// Catch variables are often unused; only reads matter.
+				l.declare(catch.Identifier, true)
+			}
+
+			l.lintNested(catch)
+		}
+
+		if finally := n.Finally(); finally != nil {
+			l.lintNested(finally)
+		}
+	case parse.ContainsChildren:
+		l.lintChildren(n)
+	}
+}
+
+func (l *linter) lintChildren(parent parse.ContainsChildren) {
+	for _, child := range parent.Children() {
+		l.lint(child)
+	}
+}
+
+// Lints the statements of a block that may not run, or
+// may not run to completion.
+func (l *linter) lintNested(block parse.ContainsChildren) {
+	l.blocks = append(l.blocks, block)
+	l.lintBlock(block.Children())
+	l.blocks = l.blocks[0 : len(l.blocks)-1]
+}
+
+func (l *linter) lintIf(node *parse.If) {
+	l.lint(node.Condition())
+
+	if value, isConstant := constant(node.Condition()); isConstant {
+		if condition, isBool := value.(bool); isBool && condition {
+			l.warn("constant-condition", "Condition is always true", node.Condition())
+		} else if isBool {
+			l.warn("unreachable-code", "Condition is always false, so this block is never run", node)
+		}
+	}
+
+	l.lintNested(node)
+}
+
+func (l *linter) declare(identifier *parse.Identifier, read bool) {
+	if _, declared := l.variables[identifier.Identifier]; declared {
+		l.warn("shadowed-variable", fmt.Sprintf("%s is already declared", identifier.Identifier), identifier)
+	}
+
+	v := &variable{declaration: identifier, read: read}
+
+	l.variables[identifier.Identifier] = v
+	l.order = append(l.order, v)
+}
+
+func (l *linter) read(identifier string) {
+	if v, declared := l.variables[identifier]; declared {
+		v.read = true
+		v.pending = nil
+	}
+}
+
+// Records a write to identifier. A write that hasn't been read
+// is dead if this write is certain to run after it, i.e. this
+// write is in the same block or one enclosing it.
+func (l *linter) write(identifier *parse.Identifier) {
+	v, declared := l.variables[identifier.Identifier]
+
+	if !declared {
+		return
+	}
+
+	if v.pending != nil && encloses(l.blocks, v.pending.blocks) && !inTry(v.pending.blocks) {
+		v.dead = append(v.dead, v.pending)
+	}
+
+	v.pending = &store{node: identifier, blocks: append([]parse.ContainsChildren{}, l.blocks...)}
+}
+
+// Whether the blocks outer are the same as, or enclose, inner.
+func encloses(outer []parse.ContainsChildren, inner []parse.ContainsChildren) bool {
+	if len(outer) > len(inner) {
+		return false
+	}
+
+	for i := range outer {
+		if outer[i] != inner[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Whether any of blocks is a try, from which an error
+// may jump to a catch that reads the value.
+func inTry(blocks []parse.ContainsChildren) bool {
+	for _, block := range blocks {
+		if _, isTry := block.(*parse.Try); isTry {
+			return true
+		}
+	}
+
+	return false
+}
+
+func throws(node parse.Node) bool {
+	if statement, isStatement := node.(*parse.Statement); isStatement {
+		if children := statement.Children(); len(children) == 1 {
+			node = children[0]
+		}
+	}
+
+	_, isThrow := node.(*parse.Throw)
+
+	return isThrow
+}
+
+// Gets the value of node if it's the same every time
+// it's evaluated: a bool, float64 or string.
+func constant(node parse.Node) (interface{}, bool) {
+	switch n := node.(type) {
+	case *parse.Boolean:
+		return n.Value, true
+	case *parse.Number:
+		return n.Value, true
+	case *parse.String:
+		return n.Value, true
+	case *parse.Group:
+		if children := n.Children(); len(children) == 1 {
+			return constant(children[0])
+		}
+	case *parse.Operator:
+		children := n.Children()
+
+		if len(children) != 2 {
+			return nil, false
+		}
+
+		left, leftConstant := constant(children[0])
+		right, rightConstant := constant(children[1])
+
+		switch n.Operator {
+		case "&&":
+			if leftConstant && left == false || rightConstant && right == false {
+				return false, true
+			}
+		case "||":
+			if leftConstant && left == true || rightConstant && right == true {
+				return true, true
+			}
+		}
+
+		if !leftConstant || !rightConstant {
+			return nil, false
+		}
+
+		switch n.Operator {
+		case "&&", "||":
+			return left == true && right == true || n.Operator == "||" && (left == true || right == true), true
+		case "==":
+			return left == right, true
+		}
+	}
+
+	return nil, false
+}
+
+// Removes the warnings suppressed by comments, which suppress
+// warnings on their own line and, unless they follow code on
+// that line, on the next.
+func Suppress(warnings lex.Diagnostics, comments []lex.Lexeme) lex.Diagnostics {
+	suppressed := map[int][]string{}
+
+	for _, comment := range comments {
+		if match := suppression.FindStringSubmatch(strings.TrimSpace(comment.Value)); match != nil {
+			codes := []string{}
+
+			for _, code := range strings.Split(match[1], ",") {
+				if code = strings.TrimSpace(code); len(code) > 0 {
+					codes = append(codes, code)
+				}
+			}
+
+			suppressed[comment.Line] = codes
+
+			if comment.Type == lex.LComment {
+				suppressed[comment.Line+1] = codes
+			}
+		}
+	}
+
+	remaining := lex.Diagnostics{}
+
+	for _, warning := range warnings {
+		codes, isSuppressed := suppressed[warning.Start.Line]
+
+		if isSuppressed && (len(codes) == 0 || contains(codes, warning.Code)) {
+			continue
+		}
+
+		remaining = append(remaining, warning)
+	}
+
+	return remaining
+}
+
+func contains(codes []string, code string) bool {
+	for _, candidate := range codes {
+		if candidate == code {
+			return true
+		}
+	}
+
+	return false
+}
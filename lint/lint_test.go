@@ -0,0 +1,107 @@
+package lint_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/lint"
+	"github.com/ehimen/jaslang/parse"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnusedVariable(t *testing.T) {
+	assertWarnings(t, `let a number = 1;
+let b number;
+let c number = 2;
+println(c);
+export let d number = 3;`, `1:5: a is declared but never read
+2:5: b is declared but never read`)
+}
+
+func TestUnusedAssignment(t *testing.T) {
+	assertWarnings(t, `let a number = 1;
+a = 2;
+println(a);
+a = 3;
+if (a == 3) { a = 4; }
+println(a);
+a = 5;
+a = 6;
+println(a);`, `1:5: Value assigned to a is never read
+7:1: Value assigned to a is never read`)
+}
+
+func TestAssignmentInBlockIsNotOverwritten(t *testing.T) {
+	assertWarnings(t, `let a number = 1;
+if (true == false) { a = 2; }
+if (a == 1) { a = 3; }
+println(a);
+try { a = 4; throw "x"; a = 5; } catch (e) { println(a); }`, `2:1: Condition is always false, so this block is never run
+5:27: Code after throw is never run`)
+}
+
+func TestShadowedVariable(t *testing.T) {
+	assertWarnings(t, `import "lib.jsl" as lib;
+let lib number = 1;
+println(lib);`, `1:21: lib is declared but never read
+2:5: lib is already declared`)
+}
+
+func TestUnreachableCode(t *testing.T) {
+	assertWarnings(t, `if (false) { println("a"); }
+if (true && false) { println("b"); }
+if ("a" == "b") { println("c"); }
+throw "d";
+println("e");`, `1:1: Condition is always false, so this block is never run
+2:1: Condition is always false, so this block is never run
+3:1: Condition is always false, so this block is never run
+5:1: Code after throw is never run`)
+}
+
+func TestConstantCondition(t *testing.T) {
+	assertWarnings(t, `let a boolean = false;
+if (true) { println("a"); }
+if (a || true) { println("b"); }
+if (a) { println("c"); }`, `2:5: Condition is always true
+3:7: Condition is always true`)
+}
+
+func TestSuppression(t *testing.T) {
+	assertWarnings(t, `// lint:ignore unused-variable
+let a number = 1;
+let b number = 1; // lint:ignore
+let c number = 1; // lint:ignore shadowed-variable
+if (false) { } // lint:ignore unused-variable, unreachable-code`, `4:5: c is declared but never read`)
+}
+
+func TestTrailingSuppressionIsForItsLineOnly(t *testing.T) {
+	assertWarnings(t, `let c number = 2; // lint:ignore
+let d number = 3;`, `2:5: d is declared but never read`)
+}
+
+func TestWarningDiagnostic(t *testing.T) {
+	warnings := lintCode(t, `let unused number = 1;`)
+
+	if assert.Len(t, warnings, 1) {
+		assert.Equal(t, lex.SeverityWarning, warnings[0].Severity)
+		assert.Equal(t, "unused-variable", warnings[0].Code)
+		assert.Equal(t, 6, warnings[0].Length())
+	}
+}
+
+func assertWarnings(t *testing.T, code string, expected string) {
+	assert.Equal(t, expected, lintCode(t, code).Error())
+}
+
+func lintCode(t *testing.T, code string) lex.Diagnostics {
+	parser := parse.NewParser(lex.NewJslLexer(strings.NewReader(code)))
+
+	ast, err := parser.Parse()
+
+	if err != nil {
+		t.Fatalf("Unexpected Parse() error: %v", err)
+	}
+
+	return lint.Lint(ast, parser.Comments())
+}
@@ -2,11 +2,13 @@ package parse
 
 import (
 	"encoding/json"
-	"errors"
+
+	"github.com/ehimen/jaslang/lex"
 )
 
 type Node interface {
 	json.Marshaler
+	File() string
 	Line() int
 	Column() int
 }
@@ -22,13 +24,28 @@ type Adjustable interface {
 	removeLastChild()
 }
 
+// Implemented by nodes that own a braced block
+// of statements, e.g. an if's body. Statements
+// inside an open block are pushed as its direct
+// children rather than being wrapped in a Statement.
+type block interface {
+	ContainsChildren
+	openBlock()
+	isOpen() bool
+}
+
 // Embedded struct to record a node's position
 // in the source.
 type position struct {
+	file   string
 	line   int
 	column int
 }
 
+func (p position) File() string {
+	return p.file
+}
+
 func (p position) Line() int {
 	return p.line
 }
@@ -37,6 +54,25 @@ func (p position) Column() int {
 	return p.column
 }
 
+// Implemented by nodes embedding position, so that the
+// parser can record which file they were parsed from.
+type locatable interface {
+	setFile(file string)
+}
+
+func (p *position) setFile(file string) {
+	p.file = file
+}
+
+func (p position) location() lex.Position {
+	return lex.Position{File: p.file, Line: p.line, Column: p.column}
+}
+
+// Gets the position of node in the source.
+func Position(node Node) lex.Position {
+	return lex.Position{File: node.File(), Line: node.Line(), Column: node.Column()}
+}
+
 // Can be embedded in to all node types that
 // have children.
 type ParentNode struct {
@@ -81,6 +117,10 @@ type RootNode struct {
 	Statements []*Statement
 }
 
+func (root RootNode) File() string {
+	return ""
+}
+
 func (root RootNode) Line() int {
 	return 1
 }
@@ -130,11 +170,28 @@ func (f FunctionCall) MarshalJSON() ([]byte, error) {
 	})
 }
 
+// An identifier, bound to its symbol's slot once resolved.
+// The identifiers of a Let or Assignment are bound to the
+// slot that is declared or assigned to.
 type Identifier struct {
 	Identifier string
+	Binding
 	position
 }
 
+// Where the symbol an identifier refers to is kept: Depth
+// scopes out from where it's used, in that scope's Slot.
+type Binding struct {
+	Depth    int
+	Slot     int
+	Resolved bool
+}
+
+// Binds the identifier to slot, depth scopes out.
+func (i *Identifier) Bind(depth int, slot int) {
+	i.Binding = Binding{Depth: depth, Slot: slot, Resolved: true}
+}
+
 func (i Identifier) MarshalJSON() ([]byte, error) {
 	return json.Marshal(i.Identifier)
 }
@@ -186,6 +243,9 @@ func (n Number) MarshalJSON() ([]byte, error) {
 
 type Operator struct {
 	Operator string
+	// The index of the operator's call site, once
+	// resolved, where evaluators cache its operation.
+	Site int
 	ParentNode
 	position
 }
@@ -212,7 +272,7 @@ type Let struct {
 func (let *Let) push(child Node) (error, bool) {
 	if let.Identifier == nil {
 		if ident, isIdentifier := child.(*Identifier); !isIdentifier {
-			return errors.New("Let requires an identifier"), false // TODO: test this
+			return lex.CodedError{Code: "invalid-let", Message: "Let requires an identifier"}, false // TODO: test this
 		} else {
 			let.Identifier = ident
 			return nil, true
@@ -221,7 +281,7 @@ func (let *Let) push(child Node) (error, bool) {
 
 	if let.Type == nil {
 		if ident, isIdentifier := child.(*Identifier); !isIdentifier {
-			return errors.New("Let requires a type identifier"), false // TODO: test this
+			return lex.CodedError{Code: "invalid-let", Message: "Let requires a type identifier"}, false // TODO: test this
 		} else {
 			let.Type = ident
 			return nil, true
@@ -289,7 +349,7 @@ func (assignment Assignment) MarshalJSON() ([]byte, error) {
 func (assignment *Assignment) push(child Node) (error, bool) {
 	if assignment.Identifier == nil {
 		if identifier, isIdentifier := child.(*Identifier); !isIdentifier {
-			return errors.New("First child of an assignment statement must be an identifier"), false
+			return lex.CodedError{Code: "invalid-assignment", Message: "First child of an assignment statement must be an identifier"}, false
 		} else {
 			assignment.Identifier = identifier
 			return nil, true
@@ -316,6 +376,7 @@ func (group Group) MarshalJSON() ([]byte, error) {
 
 type If struct {
 	condition Node
+	opened    bool
 	position
 	ParentNode
 }
@@ -333,7 +394,7 @@ func (i If) MarshalJSON() ([]byte, error) {
 }
 
 func (i *If) push(child Node) (error, bool) {
-	if nil == i.condition {
+	if !i.opened {
 		i.condition = child
 	} else {
 		return i.ParentNode.push(child)
@@ -342,16 +403,421 @@ func (i *If) push(child Node) (error, bool) {
 	return nil, true
 }
 
+// Until the body is opened, the condition is the
+// last child so that operators can reshuffle it.
+func (i *If) getLastChild() Node {
+	if !i.opened {
+		return i.condition
+	}
+
+	return i.ParentNode.getLastChild()
+}
+
+func (i *If) removeLastChild() {
+	if !i.opened {
+		i.condition = nil
+	} else {
+		i.ParentNode.removeLastChild()
+	}
+}
+
+func (i *If) openBlock() {
+	i.opened = true
+}
+
+func (i *If) isOpen() bool {
+	return i.opened
+}
+
 func (i If) Condition() Node {
 	return i.condition
 }
 
+type Match struct {
+	subject Node
+	arms    []*MatchArm
+	opened  bool
+	position
+}
+
+func (m Match) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type    string
+		Subject Node
+		Arms    []*MatchArm
+	}{
+		Type:    "match",
+		Subject: m.subject,
+		Arms:    m.arms,
+	})
+}
+
+func (m *Match) push(child Node) (error, bool) {
+	if !m.opened {
+		m.subject = child
+
+		return nil, true
+	}
+
+	if arm, isArm := child.(*MatchArm); !isArm {
+		return lex.CodedError{Code: "invalid-match", Message: "Match may only contain arms"}, false
+	} else {
+		m.arms = append(m.arms, arm)
+	}
+
+	return nil, true
+}
+
+func (m *Match) getLastChild() Node {
+	if !m.opened {
+		return m.subject
+	}
+
+	// Arms can't be adjusted.
+	return nil
+}
+
+func (m *Match) removeLastChild() {
+	if !m.opened {
+		m.subject = nil
+	}
+}
+
+func (m *Match) openBlock() {
+	m.opened = true
+}
+
+func (m *Match) isOpen() bool {
+	return m.opened
+}
+
+func (m *Match) Children() []Node {
+	children := []Node{}
+
+	for _, arm := range m.arms {
+		children = append(children, arm)
+	}
+
+	return children
+}
+
+func (m Match) Subject() Node {
+	return m.subject
+}
+
+func (m Match) Arms() []*MatchArm {
+	return m.arms
+}
+
+// A single arm of a match. Values are the literals
+// the subject is compared against; a wildcard arm
+// matches anything.
+type MatchArm struct {
+	values   []Node
+	wildcard bool
+	opened   bool
+	// The index of the first value's call site, comparing
+	// it with the subject, once resolved. The rest follow.
+	sites int
+	ParentNode
+	position
+}
+
+func (arm MatchArm) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type     string
+		Values   []Node
+		Wildcard bool
+		Children []Node
+	}{
+		Type:     "arm",
+		Values:   arm.values,
+		Wildcard: arm.wildcard,
+		Children: arm.children,
+	})
+}
+
+func (arm *MatchArm) push(child Node) (error, bool) {
+	if !arm.opened {
+		arm.values = append(arm.values, child)
+
+		return nil, true
+	}
+
+	return arm.ParentNode.push(child)
+}
+
+func (arm *MatchArm) getLastChild() Node {
+	if !arm.opened {
+		// Values can't be adjusted.
+		return nil
+	}
+
+	return arm.ParentNode.getLastChild()
+}
+
+func (arm *MatchArm) removeLastChild() {
+	if arm.opened {
+		arm.ParentNode.removeLastChild()
+	}
+}
+
+func (arm *MatchArm) openBlock() {
+	arm.opened = true
+}
+
+func (arm *MatchArm) isOpen() bool {
+	return arm.opened
+}
+
+func (arm MatchArm) Values() []Node {
+	return arm.values
+}
+
+func (arm MatchArm) Wildcard() bool {
+	return arm.wildcard
+}
+
+// Binds the arm's values to call sites from first on.
+func (arm *MatchArm) BindSites(first int) {
+	arm.sites = first
+}
+
+// Gets the index of the call site of the ith value.
+func (arm MatchArm) Site(i int) int {
+	return arm.sites + i
+}
+
+type Throw struct {
+	ParentNode
+	position
+}
+
+func (t Throw) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type     string
+		Children []Node
+	}{
+		Type:     "throw",
+		Children: t.children,
+	})
+}
+
+// A try block, with its optional catch and finally blocks.
+// Once the try's own block is closed, it accepts only
+// its catch and finally.
+type Try struct {
+	catch   *Catch
+	finally *Finally
+	opened  bool
+	closed  bool
+	ParentNode
+	position
+}
+
+func (t Try) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type     string
+		Children []Node
+		Catch    *Catch
+		Finally  *Finally
+	}{
+		Type:     "try",
+		Children: t.children,
+		Catch:    t.catch,
+		Finally:  t.finally,
+	})
+}
+
+func (t *Try) push(child Node) (error, bool) {
+	if !t.closed {
+		return t.ParentNode.push(child)
+	}
+
+	if catch, isCatch := child.(*Catch); isCatch && t.catch == nil && t.finally == nil {
+		t.catch = catch
+	} else if finally, isFinally := child.(*Finally); isFinally && t.finally == nil {
+		t.finally = finally
+	} else {
+		return lex.CodedError{Code: "invalid-try", Message: "Try may only be followed by one catch and one finally"}, false
+	}
+
+	return nil, true
+}
+
+func (t *Try) getLastChild() Node {
+	if t.closed {
+		// Catch and finally can't be adjusted.
+		return nil
+	}
+
+	return t.ParentNode.getLastChild()
+}
+
+func (t *Try) removeLastChild() {
+	if !t.closed {
+		t.ParentNode.removeLastChild()
+	}
+}
+
+func (t *Try) openBlock() {
+	t.opened = true
+}
+
+func (t *Try) isOpen() bool {
+	return t.opened && !t.closed
+}
+
+// Closes the try's own block, leaving it
+// ready to accept a catch or finally.
+func (t *Try) closeBlock() {
+	t.closed = true
+}
+
+func (t Try) Catch() *Catch {
+	return t.catch
+}
+
+func (t Try) Finally() *Finally {
+	return t.finally
+}
+
+type Catch struct {
+	Identifier *Identifier
+	opened     bool
+	ParentNode
+	position
+}
+
+func (c Catch) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type       string
+		Identifier *Identifier
+		Children   []Node
+	}{
+		Type:       "catch",
+		Identifier: c.Identifier,
+		Children:   c.children,
+	})
+}
+
+func (c *Catch) push(child Node) (error, bool) {
+	if c.Identifier == nil {
+		if identifier, isIdentifier := child.(*Identifier); !isIdentifier {
+			return lex.CodedError{Code: "invalid-catch", Message: "Catch requires an identifier"}, false
+		} else {
+			c.Identifier = identifier
+			return nil, true
+		}
+	}
+
+	return c.ParentNode.push(child)
+}
+
+func (c *Catch) openBlock() {
+	c.opened = true
+}
+
+func (c *Catch) isOpen() bool {
+	return c.opened
+}
+
+type Finally struct {
+	opened bool
+	ParentNode
+	position
+}
+
+func (f Finally) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type     string
+		Children []Node
+	}{
+		Type:     "finally",
+		Children: f.children,
+	})
+}
+
+func (f *Finally) openBlock() {
+	f.opened = true
+}
+
+func (f *Finally) isOpen() bool {
+	return f.opened
+}
+
+// Imports the module at Path, making its
+// exports available through Alias.
+type Import struct {
+	Path  *String
+	Alias *Identifier
+	position
+}
+
+func (i Import) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string
+		Path  string
+		Alias *Identifier
+	}{
+		Type:  "import",
+		Path:  i.Path.Value,
+		Alias: i.Alias,
+	})
+}
+
+func (i *Import) push(child Node) (error, bool) {
+	if i.Path == nil {
+		if path, isString := child.(*String); !isString {
+			return lex.CodedError{Code: "invalid-import", Message: "Import requires a path"}, false
+		} else {
+			i.Path = path
+			return nil, true
+		}
+	}
+
+	if i.Alias == nil {
+		if alias, isIdentifier := child.(*Identifier); !isIdentifier {
+			return lex.CodedError{Code: "invalid-import", Message: "Import requires an alias"}, false
+		} else {
+			i.Alias = alias
+			return nil, true
+		}
+	}
+
+	return lex.CodedError{Code: "invalid-import", Message: "Import has too many children"}, false
+}
+
+// Path and alias aren't evaluated as children.
+func (i *Import) Children() []Node {
+	return []Node{}
+}
+
+type Export struct {
+	ParentNode
+	position
+}
+
+func (e Export) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type     string
+		Children []Node
+	}{
+		Type:     "export",
+		Children: e.children,
+	})
+}
+
 func NewStatement(line int, column int, children ...Node) *Statement {
 	return &Statement{ParentNode: ParentNode{children: children}, position: position{line: line, column: column}}
 }
 
 func NewFunctionCall(identifier string, line int, column int, children ...Node) *FunctionCall {
-	return &FunctionCall{Identifier: NewIdentifier(identifier, line, column), ParentNode: ParentNode{children: children}}
+	return &FunctionCall{
+		Identifier: NewIdentifier(identifier, line, column),
+		ParentNode: ParentNode{children: children},
+		position:   position{line: line, column: column},
+	}
 }
 
 func NewIdentifier(identifier string, line int, column int) *Identifier {
@@ -394,3 +860,53 @@ func NewAssignment(line int, column int) *Assignment {
 func NewIf(line int, column int) *If {
 	return &If{position: position{line: line, column: column}}
 }
+
+func NewMatch(subject Node, line int, column int, arms ...*MatchArm) *Match {
+	return &Match{subject: subject, arms: arms, opened: true, position: position{line: line, column: column}}
+}
+
+func NewMatchArm(values []Node, wildcard bool, line int, column int, children ...Node) *MatchArm {
+	return &MatchArm{
+		values:     values,
+		wildcard:   wildcard,
+		opened:     true,
+		ParentNode: ParentNode{children: children},
+		position:   position{line: line, column: column},
+	}
+}
+
+func NewThrow(line int, column int, children ...Node) *Throw {
+	return &Throw{ParentNode: ParentNode{children: children}, position: position{line: line, column: column}}
+}
+
+func NewTry(line int, column int, catch *Catch, finally *Finally, children ...Node) *Try {
+	return &Try{
+		catch:      catch,
+		finally:    finally,
+		opened:     true,
+		closed:     true,
+		ParentNode: ParentNode{children: children},
+		position:   position{line: line, column: column},
+	}
+}
+
+func NewCatch(identifier *Identifier, line int, column int, children ...Node) *Catch {
+	return &Catch{
+		Identifier: identifier,
+		opened:     true,
+		ParentNode: ParentNode{children: children},
+		position:   position{line: line, column: column},
+	}
+}
+
+func NewFinally(line int, column int, children ...Node) *Finally {
+	return &Finally{opened: true, ParentNode: ParentNode{children: children}, position: position{line: line, column: column}}
+}
+
+func NewImport(path *String, alias *Identifier, line int, column int) *Import {
+	return &Import{Path: path, Alias: alias, position: position{line: line, column: column}}
+}
+
+func NewExport(line int, column int, children ...Node) *Export {
+	return &Export{ParentNode: ParentNode{children: children}, position: position{line: line, column: column}}
+}
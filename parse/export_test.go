@@ -0,0 +1,22 @@
+package parse
+
+// The machines parsers are cloned from, which
+// parse_test validates.
+var Machines = map[string]func() grammarMachine{
+	"statement":  statementGrammar,
+	"expression": expressionGrammar,
+}
+
+// Gets the types of lexemes that aren't given
+// the symbol that's said to be given for them.
+func MismatchedSymbolTypes() []string {
+	mismatched := []string{}
+
+	for s, t := range symbolTypes {
+		if s != int(unknown) && symbolOf(t) != symbol(s) {
+			mismatched = append(mismatched, t.String())
+		}
+	}
+
+	return mismatched
+}
@@ -1,111 +1,482 @@
 package parse
 
 import (
+	"fmt"
+	"sync"
+
 	"github.com/ehimen/jaslang/dfa"
 	"github.com/ehimen/jaslang/lex"
 )
 
-var start = "start"
-var identifier = lex.LIdentifier.String()
-var parenOpen = lex.LParenOpen.String()
-var parenClose = lex.LParenClose.String()
-var quoted = lex.LQuoted.String()
-var term = lex.LSemiColon.String()
-var number = lex.LNumber.String()
-var ltrue = lex.LBoolTrue.String()
-var lfalse = lex.LBoolFalse.String()
-var operator = lex.LOperator.String()
-var let = lex.LLet.String()
-var equals = lex.LEquals.String()
-var comma = lex.LComma.String()
-var lif = lex.LIf.String()
-var braceOpen = lex.LBraceOpen.String()
-var braceClose = lex.LBraceClose.String()
-
-func buildDfa(p *parser) (dfa.Machine, error) {
-
-	builder := dfa.NewMachineBuilder()
-
-	builder.Path(start, quoted, quoted)
-	builder.Path(start, ltrue, ltrue)
-	builder.Path(start, lfalse, lfalse)
-	builder.Path(start, let, let)
-	builder.Path(start, term, start)
+// What the parser's DFA moves via: the type of a lexeme or,
+// where the DFA alone can't tell lexemes of one type apart,
+// a symbol the parser gives them in place of their type.
+// Symbols are numbered so that moving is cheap.
+type symbol int
 
-	defaultExprPrefix := buildExpr(p, builder, "", start, term, start)
+const (
+	// Given to lexemes of types the grammar doesn't use.
+	unknown symbol = iota
+	identifier
+	parenOpen
+	parenClose
+	quoted
+	term
+	number
+	ltrue
+	lfalse
+	operator
+	let
+	equals
+	comma
+	lif
+	braceOpen
+	braceClose
+	match
+	throw
+	try
+	catch
+	finally
+	limport
+	as
+	export
 
-	// Assignment is only allowed as the first in statement (not in expr itself).
-	builder.Path(defaultExprPrefix+identifier, equals, equals)
-	buildExpr(p, builder, "assignment", equals, term, start)
+	// The symbols from here on aren't types of lexemes.
+	// This is a closing brace that ends a match arm's body.
+	matchArmClose
+	// A closing brace that ends the block of a try.
+	tryClose
+	// The operator "=>", which only separates the
+	// values of a match arm from its body.
+	arrow
+	// The end of an expression parser's input.
+	endOfInput
+)
+
+// The types of lexemes that symbols are given for.
+var symbolTypes = [...]lex.LexemeType{
+	identifier: lex.LIdentifier,
+	parenOpen:  lex.LParenOpen,
+	parenClose: lex.LParenClose,
+	quoted:     lex.LQuoted,
+	term:       lex.LSemiColon,
+	number:     lex.LNumber,
+	ltrue:      lex.LBoolTrue,
+	lfalse:     lex.LBoolFalse,
+	operator:   lex.LOperator,
+	let:        lex.LLet,
+	equals:     lex.LEquals,
+	comma:      lex.LComma,
+	lif:        lex.LIf,
+	braceOpen:  lex.LBraceOpen,
+	braceClose: lex.LBraceClose,
+	match:      lex.LMatch,
+	throw:      lex.LThrow,
+	try:        lex.LTry,
+	catch:      lex.LCatch,
+	finally:    lex.LFinally,
+	limport:    lex.LImport,
+	as:         lex.LAs,
+	export:     lex.LExport,
+}
+
+// Gets the symbol given for lexemes of type t. Types are
+// strings, so this switches on them rather than hashing
+// them to look them up.
+func symbolOf(t lex.LexemeType) symbol {
+	switch t {
+	case lex.LIdentifier:
+		return identifier
+	case lex.LParenOpen:
+		return parenOpen
+	case lex.LParenClose:
+		return parenClose
+	case lex.LQuoted:
+		return quoted
+	case lex.LSemiColon:
+		return term
+	case lex.LNumber:
+		return number
+	case lex.LBoolTrue:
+		return ltrue
+	case lex.LBoolFalse:
+		return lfalse
+	case lex.LOperator:
+		return operator
+	case lex.LLet:
+		return let
+	case lex.LEquals:
+		return equals
+	case lex.LComma:
+		return comma
+	case lex.LIf:
+		return lif
+	case lex.LBraceOpen:
+		return braceOpen
+	case lex.LBraceClose:
+		return braceClose
+	case lex.LMatch:
+		return match
+	case lex.LThrow:
+		return throw
+	case lex.LTry:
+		return try
+	case lex.LCatch:
+		return catch
+	case lex.LFinally:
+		return finally
+	case lex.LImport:
+		return limport
+	case lex.LAs:
+		return as
+	case lex.LExport:
+		return export
+	}
+
+	return unknown
+}
+
+func (s symbol) String() string {
+	switch s {
+	case unknown:
+		return "unknown"
+	case matchArmClose:
+		return "match-arm-" + braceClose.String()
+	case tryClose:
+		return "try-" + braceClose.String()
+	case arrow:
+		return "=>"
+	case endOfInput:
+		return "end-of-input"
+	}
+
+	return symbolTypes[s].String()
+}
+
+// A state of the parser's DFA. The states of statements
+// are named here; each context an expression is parsed in
+// has its own expression states, numbered after them.
+type state int
+
+const (
+	start state = iota
+	inLet
+	letIdentifier
+	letTypeIdentifier
+	letEquals
+	assigning
+	inIf
+	ifOpened
+	ifBlock
+	inMatch
+	matchOpened
+	matchBlock
+	matchArms
+	matchArmComma
+	matchArmValue
+	matchArrow
+	inThrow
+	inTry
+	tryClosed
+	inCatch
+	catchOpened
+	catchIdentifier
+	catchBlock
+	inFinally
+	inImport
+	importPath
+	importAs
+	importAlias
+	inExport
+	expressionEnd
+	exprStates
+)
+
+// States entered via a keyword or punctuation
+// are named after its type.
+var stateNames = [...]string{
+	start:             "start",
+	inLet:             let.String(),
+	letIdentifier:     "let-identifier",
+	letTypeIdentifier: "let-type-identifier",
+	letEquals:         "let-equals",
+	assigning:         equals.String(),
+	inIf:              lif.String(),
+	ifOpened:          "if-opened",
+	ifBlock:           "if-block",
+	inMatch:           match.String(),
+	matchOpened:       "match-opened",
+	matchBlock:        "match-block",
+	matchArms:         "match-arms",
+	matchArmComma:     "match-arm-comma",
+	matchArmValue:     "match-arm-value",
+	matchArrow:        "match-arrow",
+	inThrow:           throw.String(),
+	inTry:             try.String(),
+	tryClosed:         "try-closed",
+	inCatch:           catch.String(),
+	catchOpened:       "catch-opened",
+	catchIdentifier:   "catch-identifier",
+	catchBlock:        "catch-block",
+	inFinally:         finally.String(),
+	inImport:          limport.String(),
+	importPath:        "import-path",
+	importAs:          "import-as",
+	importAlias:       "import-alias",
+	inExport:          export.String(),
+	expressionEnd:     "expression-end",
+}
+
+func (s state) String() string {
+	if s < exprStates {
+		return stateNames[s]
+	}
+
+	context, at := (s-exprStates)/exprStateCount, (s-exprStates)%exprStateCount
+
+	return exprPrefixes[context] + exprStateTypes[at].String()
+}
+
+// Where an expression is parsed, each with its own
+// section of the DFA so it can return to where it was.
+type exprContext int
+
+const (
+	statementExpr exprContext = iota
+	assignmentExpr
+	ifConditionExpr
+	matchSubjectExpr
+	throwExpr
+	letExpr
+)
+
+var exprPrefixes = [...]string{
+	statementExpr:    "expr-",
+	assignmentExpr:   "assignment-expr-",
+	ifConditionExpr:  "if-condition-expr-",
+	matchSubjectExpr: "match-subject-expr-",
+	throwExpr:        "throw-expr-",
+	letExpr:          "let-expr-",
+}
+
+// The states within an expression, each entered
+// via and named after a type of lexeme.
+const (
+	atNumber state = iota
+	atString
+	atBoolTrue
+	atBoolFalse
+	atOperator
+	atIdentifier
+	atParenOpen
+	atParenClose
+	atComma
+	exprStateCount
+)
+
+var exprStateTypes = [...]lex.LexemeType{
+	atNumber:     lex.LNumber,
+	atString:     lex.LQuoted,
+	atBoolTrue:   lex.LBoolTrue,
+	atBoolFalse:  lex.LBoolFalse,
+	atOperator:   lex.LOperator,
+	atIdentifier: lex.LIdentifier,
+	atParenOpen:  lex.LParenOpen,
+	atParenClose: lex.LParenClose,
+	atComma:      lex.LComma,
+}
+
+// Gets the state at, such as atNumber, of expressions
+// parsed in this context.
+func (c exprContext) state(at state) state {
+	return exprStates + state(c)*exprStateCount + at
+}
 
-	builder.Path(quoted, term, start)
-	builder.Path(quoted, parenClose, parenClose)
+// The parser's DFA moves between states via symbols,
+// both numbered so that moving is cheap.
+type grammarMachine = dfa.TypedMachine[state, symbol]
+type grammarBuilder = dfa.TypedMachineBuilder[state, symbol]
 
-	builder.Path(number, term, start)
+// The grammars of statements and of expressions. Each is
+// built once, when first needed, and cloned by each parser.
+var statementGrammar = grammar(buildDfa)
+var expressionGrammar = grammar(buildExpressionDfa)
 
-	builder.Path(ltrue, term, start)
+func grammar(build func() (grammarMachine, error)) func() grammarMachine {
+	var once sync.Once
+	var machine grammarMachine
 
-	builder.Path(lfalse, term, start)
+	return func() grammarMachine {
+		once.Do(func() {
+			built, err := build()
+
+			if err != nil {
+				panic(fmt.Sprintf("Cannot build parse machine: %v", err))
+			}
+
+			machine = built
+		})
+
+		return machine
+	}
+}
+
+// Adapts a method of the parser to be called by the DFA
+// with the parser it's cloned for, named after the method.
+func with(method func(*parser) error) dfa.Hook {
+	return dfa.Hook{Name: dfa.FuncName(method), Call: func(subject interface{}) error {
+		return method(subject.(*parser))
+	}}
+}
+
+// Describes the grammar of statements that parsers parse,
+// as a state machine.
+func Grammar() dfa.Description {
+	return statementGrammar().Describe()
+}
+
+// Describes the grammar of single expressions that expression
+// parsers parse, as a state machine.
+func ExpressionGrammar() dfa.Description {
+	return expressionGrammar().Describe()
+}
+
+func buildDfa() (grammarMachine, error) {
+
+	builder := dfa.NewTypedMachineBuilder[state, symbol]()
+
+	builder.Path(start, let, inLet)
+	builder.Path(start, term, start)
+
+	buildExpr(builder, statementExpr, start, term, start)
+
+	// Assignment is only allowed as the first in statement (not in expr itself).
+	builder.Path(statementExpr.state(atIdentifier), equals, assigning)
+	buildExpr(builder, assignmentExpr, assigning, term, start)
 
 	// If statements
-	builder.Path(start, lif, lif)
-	builder.Path(lif, parenOpen, "if-opened")
-	buildExpr(p, builder, "if-condition", "if-opened", parenClose, "if-block")
-	builder.Path("if-block", braceOpen, start)
+	builder.Path(start, lif, inIf)
+	builder.Path(inIf, parenOpen, ifOpened)
+	buildExpr(builder, ifConditionExpr, ifOpened, parenClose, ifBlock)
+	builder.Path(ifBlock, braceOpen, start)
 	builder.Path(start, braceClose, start)
 
-	builder.Path(let, identifier, "let-identifier")
-	builder.Path("let-identifier", identifier, "let-type-identifier")
-	builder.Path("let-type-identifier", term, start)
-	builder.Path("let-type-identifier", equals, "let-equals")
-	buildExpr(p, builder, "let", "let-equals", term, start)
-	builder.WhenEntering("let-identifier", p.createIdentifier)
-	builder.WhenEntering("let-type-identifier", p.createIdentifier)
-
-	builder.WhenEntering(quoted, p.createStringLiteral)
-	builder.WhenEntering(parenClose, p.closeGroupOrFunction)
-	builder.WhenEntering(number, p.createNumberLiteral)
-	builder.WhenEntering(ltrue, p.createBooleanLiteral)
-	builder.WhenEntering(lfalse, p.createBooleanLiteral)
-	builder.WhenEntering(operator, p.createOperator)
-	builder.WhenEntering(let, p.createLet)
-	builder.WhenEntering(parenOpen, p.createGroup)
-	builder.WhenEntering(equals, p.createAssignment)
-	builder.WhenEntering(lif, p.createIf)
-	builder.WhenTransitioningVia(term, p.closeStatement)
+	// Match statements
+	builder.Path(start, match, inMatch)
+	builder.Path(inMatch, parenOpen, matchOpened)
+	buildExpr(builder, matchSubjectExpr, matchOpened, parenClose, matchBlock)
+	builder.Path(matchBlock, braceOpen, matchArms)
+	builder.Paths([]state{matchArms, matchArmComma}, number, []state{matchArmValue})
+	builder.Paths([]state{matchArms, matchArmComma}, quoted, []state{matchArmValue})
+	builder.Paths([]state{matchArms, matchArmComma}, ltrue, []state{matchArmValue})
+	builder.Paths([]state{matchArms, matchArmComma}, lfalse, []state{matchArmValue})
+	builder.Paths([]state{matchArms, matchArmComma}, identifier, []state{matchArmValue})
+	builder.Path(matchArmValue, comma, matchArmComma)
+	builder.Path(matchArmValue, arrow, matchArrow)
+	builder.Path(matchArrow, braceOpen, start)
+	builder.Path(start, matchArmClose, matchArms)
+	builder.Path(matchArms, braceClose, start)
+
+	// Exceptions
+	builder.Path(start, throw, inThrow)
+	buildExpr(builder, throwExpr, inThrow, term, start)
+	builder.Path(start, try, inTry)
+	builder.Path(inTry, braceOpen, start)
+	builder.Path(start, tryClose, tryClosed)
+	builder.Path(tryClosed, catch, inCatch)
+	builder.Path(tryClosed, finally, inFinally)
+	builder.Path(inCatch, parenOpen, catchOpened)
+	builder.Path(catchOpened, identifier, catchIdentifier)
+	builder.Path(catchIdentifier, parenClose, catchBlock)
+	builder.Path(catchBlock, braceOpen, start)
+	// Finally may follow a closed catch block, which returns to start.
+	builder.Path(start, finally, inFinally)
+	builder.Path(inFinally, braceOpen, start)
+
+	// Modules
+	builder.Path(start, limport, inImport)
+	builder.Path(inImport, quoted, importPath)
+	builder.Path(importPath, as, importAs)
+	builder.Path(importAs, identifier, importAlias)
+	builder.Path(importAlias, term, start)
+	builder.Path(start, export, inExport)
+	builder.Path(inExport, let, inLet)
+
+	builder.Path(inLet, identifier, letIdentifier)
+	builder.Path(letIdentifier, identifier, letTypeIdentifier)
+	builder.Path(letTypeIdentifier, term, start)
+	builder.Path(letTypeIdentifier, equals, letEquals)
+	buildExpr(builder, letExpr, letEquals, term, start)
+	builder.WhenEnteringWith(letIdentifier, with((*parser).createIdentifier))
+	builder.WhenEnteringWith(letTypeIdentifier, with((*parser).createIdentifier))
+
+	builder.WhenEnteringWith(inLet, with((*parser).createLet))
+	builder.WhenEnteringWith(assigning, with((*parser).createAssignment))
+	builder.WhenEnteringWith(inIf, with((*parser).createIf))
+	builder.WhenEnteringWith(ifBlock, with((*parser).closeBlockHeader))
+	builder.WhenEnteringWith(inMatch, with((*parser).createMatch))
+	builder.WhenEnteringWith(matchBlock, with((*parser).closeBlockHeader))
+	builder.WhenEnteringWith(matchArmValue, with((*parser).createMatchArmValue))
+	builder.WhenEnteringWith(inThrow, with((*parser).createThrow))
+	builder.WhenEnteringWith(inTry, with((*parser).createTry))
+	builder.WhenEnteringWith(inCatch, with((*parser).createCatch))
+	builder.WhenEnteringWith(catchIdentifier, with((*parser).createIdentifier))
+	builder.WhenEnteringWith(inFinally, with((*parser).createFinally))
+	builder.WhenEnteringWith(inImport, with((*parser).createImport))
+	builder.WhenEnteringWith(importPath, with((*parser).createStringLiteral))
+	builder.WhenEnteringWith(importAlias, with((*parser).createIdentifier))
+	builder.WhenEnteringWith(inExport, with((*parser).createExport))
+	builder.WhenTransitioningWith(term, with((*parser).closeStatement))
+	builder.WhenTransitioningWith(braceOpen, with((*parser).openBlock))
+	builder.WhenTransitioningWith(braceClose, with((*parser).closeBlock))
+	builder.WhenTransitioningWith(matchArmClose, with((*parser).closeBlock))
+	builder.WhenTransitioningWith(tryClose, with((*parser).closeTryBlock))
 
 	builder.Accept(start)
 
 	return builder.Start(start)
 }
 
+// Builds the DFA of an expression parser, which is only the
+// section built by buildExpr, ending at the end of input.
+// Unlike after "=", an expression may start with a group.
+func buildExpressionDfa() (grammarMachine, error) {
+	builder := dfa.NewTypedMachineBuilder[state, symbol]()
+
+	buildExpr(builder, statementExpr, start, endOfInput, expressionEnd)
+	builder.Path(start, parenOpen, statementExpr.state(atParenOpen))
+	builder.Accept(expressionEnd)
+
+	return builder.Start(start)
+}
+
 // Builds rules for when expressions are allowed.
-// This creates a new section of the DFA with a prefix
+// This creates a new section of the DFA for context
 // that is entered following a particular token.
 // For example, the expression allowed after the assignment
 // operator. These nodes are in the let-specific section of the
 // the DFA.
 //
-// This function returns the prefix used so that the built portion
-// of the AST can be extended externally.
-// For example, at the beginning of statement we want to extend
-// identifier to allow an equals to follow (for assignment).
-func buildExpr(p *parser, b dfa.MachineBuilder, prefix string, from string, returnVia string, returnTo string) string {
-	if len(prefix) > 0 {
-		prefix = prefix + "-expr-"
-	} else {
-		prefix = "expr-"
-	}
+// The states of the section are got from context, so that
+// the built portion can be extended externally. For example,
+// at the beginning of statement we want to extend identifier
+// to allow an equals to follow (for assignment).
+func buildExpr(b grammarBuilder, context exprContext, from state, returnVia symbol, returnTo state) {
+	exprNumber := context.state(atNumber)
+	exprString := context.state(atString)
+	exprBoolTrue := context.state(atBoolTrue)
+	exprBoolFalse := context.state(atBoolFalse)
+	exprOperator := context.state(atOperator)
+	exprIdentifier := context.state(atIdentifier)
+	exprParenOpen := context.state(atParenOpen)
+	exprParenClose := context.state(atParenClose)
+	exprComma := context.state(atComma)
 
-	exprNumber := prefix + lex.LNumber.String()
-	exprString := prefix + lex.LQuoted.String()
-	exprBoolTrue := prefix + lex.LBoolTrue.String()
-	exprBoolFalse := prefix + lex.LBoolFalse.String()
-	exprOperator := prefix + lex.LOperator.String()
-	exprIdentifier := prefix + lex.LIdentifier.String()
-	exprParenOpen := prefix + lex.LParenOpen.String()
-	exprParenClose := prefix + lex.LParenClose.String()
-	exprComma := prefix + lex.LComma.String()
+	// When the expression is returned from via a closing paren, as
+	// an if's condition is, a closing paren after an operand returns
+	// rather than closing a group, and one after a closed group
+	// closes its enclosing group rather than returning.
+	closesGroups := returnVia != parenClose
 
 	b.Path(from, number, exprNumber)
 	b.Path(from, identifier, exprIdentifier)
@@ -117,8 +488,7 @@ func buildExpr(p *parser, b dfa.MachineBuilder, prefix string, from string, retu
 	// TODO: test double if parens: if ((something)) {...
 	b.Path(exprIdentifier, operator, exprOperator)
 	b.Path(exprIdentifier, parenOpen, exprParenOpen)
-	b.Path(exprIdentifier, returnVia, returnTo) // We do this before parenClose as if requires this to close the condition
-	b.Path(exprIdentifier, parenClose, exprParenClose)
+	b.Path(exprIdentifier, returnVia, returnTo)
 	b.Path(exprIdentifier, comma, exprComma)
 	b.Path(exprParenOpen, quoted, exprString)
 	b.Path(exprParenOpen, number, exprNumber)
@@ -132,35 +502,33 @@ func buildExpr(p *parser, b dfa.MachineBuilder, prefix string, from string, retu
 	b.Path(exprOperator, parenOpen, exprParenOpen)
 	b.Path(exprNumber, operator, exprOperator)
 	b.Path(exprNumber, returnVia, returnTo)
-	b.Path(exprNumber, parenClose, exprParenClose)
 	b.Path(exprNumber, comma, exprComma)
-	b.Path(exprString, parenClose, exprParenClose)
+	b.Path(exprString, returnVia, returnTo)
 	b.Path(exprString, operator, exprOperator)
 	b.Path(exprString, comma, exprComma)
-	b.Path(exprString, returnVia, returnTo)
 	b.Path(exprComma, quoted, exprString)
 	b.Path(exprComma, identifier, exprIdentifier)
 	b.Path(exprBoolTrue, returnVia, returnTo)
 	b.Path(exprBoolFalse, returnVia, returnTo)
 	b.Path(exprBoolTrue, operator, exprOperator)
-	b.Path(exprBoolFalse, returnVia, returnTo)
+	b.Path(exprBoolFalse, operator, exprOperator)
 	b.Path(exprBoolTrue, comma, exprComma)
 	b.Path(exprBoolFalse, comma, exprComma)
-	b.Path(exprBoolTrue, parenClose, exprParenClose)
-	b.Path(exprBoolFalse, parenClose, exprParenClose)
 	b.Path(exprParenClose, operator, exprOperator)
 	b.Path(exprParenClose, parenClose, exprParenClose)
-	b.Path(exprParenClose, returnVia, returnTo)
-
-	b.WhenEntering(exprNumber, p.createNumberLiteral)
-	b.WhenEntering(exprString, p.createStringLiteral)
-	b.WhenEntering(exprBoolTrue, p.createBooleanLiteral)
-	b.WhenEntering(exprBoolFalse, p.createBooleanLiteral)
-	b.WhenEntering(exprIdentifier, p.createIdentifier)
-	b.WhenEntering(exprOperator, p.createOperator)
-	b.WhenEntering(exprComma, p.closeArgument)
-	b.WhenEntering(exprParenOpen, p.createGroup)
-	b.WhenEntering(exprParenClose, p.closeGroupOrFunction)
-
-	return prefix
+
+	if closesGroups {
+		b.Paths([]state{exprIdentifier, exprNumber, exprString, exprBoolTrue, exprBoolFalse}, parenClose, []state{exprParenClose})
+		b.Path(exprParenClose, returnVia, returnTo)
+	}
+
+	b.WhenEnteringWith(exprNumber, with((*parser).createNumberLiteral))
+	b.WhenEnteringWith(exprString, with((*parser).createStringLiteral))
+	b.WhenEnteringWith(exprBoolTrue, with((*parser).createBooleanLiteral))
+	b.WhenEnteringWith(exprBoolFalse, with((*parser).createBooleanLiteral))
+	b.WhenEnteringWith(exprIdentifier, with((*parser).createIdentifier))
+	b.WhenEnteringWith(exprOperator, with((*parser).createOperator))
+	b.WhenEnteringWith(exprComma, with((*parser).closeArgument))
+	b.WhenEnteringWith(exprParenOpen, with((*parser).createGroup))
+	b.WhenEnteringWith(exprParenClose, with((*parser).closeGroupOrFunction))
 }
@@ -3,9 +3,11 @@ package parse
 import (
 	"fmt"
 
+	"sort"
+
 	"strconv"
 
-	"errors"
+	"strings"
 
 	"github.com/ehimen/jaslang/config"
 	"github.com/ehimen/jaslang/dfa"
@@ -14,11 +16,15 @@ import (
 
 type Parser interface {
 	Parse() (RootNode, error)
+	Warnings() []Warning
+	// Gets the comments in the parsed code, which
+	// otherwise aren't part of the AST.
+	Comments() []lex.Lexeme
 }
 
 type parser struct {
 	lexer          lex.Lexer
-	dfa            dfa.Machine
+	dfa            grammarMachine
 	current        lex.Lexeme
 	next           lex.Lexeme
 	nodeStack      []ContainsChildren
@@ -26,22 +32,31 @@ type parser struct {
 	operators      *Register
 	ast            *RootNode
 	openedFunction bool
+	warnings       []Warning
+	comments       []lex.Lexeme
+	recovering     bool
+	debug          bool
+	// Whether the parser parses a single expression.
+	expression     bool
+	errors         Errors
+	synchronisedAt lex.Lexeme
 }
 
 type UnexpectedTokenError struct {
 	Lexeme lex.Lexeme
-	Debug  string
+	// Descriptions of the tokens that could have been
+	// parsed instead, if known, e.g. "\";\"" or "operator".
+	Expected []string
+	Debug    string
+	// Whether to describe Debug, as config.Debug
+	// does for every unexpected token.
+	debug bool
 }
 
 func (err UnexpectedTokenError) Error() string {
-	msg := fmt.Sprintf(
-		"Unexpected token \"%s\" (position %d, line %d)",
-		err.Lexeme.Value,
-		err.Lexeme.Start,
-		err.Lexeme.Line,
-	)
-
-	if config.Debug {
+	msg := fmt.Sprintf("%s: %s", err.Lexeme.Position(), err.message())
+
+	if err.debug || config.Debug {
 		msg = fmt.Sprintf(
 			"%s\nDebug: %s",
 			msg,
@@ -52,17 +67,136 @@ func (err UnexpectedTokenError) Error() string {
 	return msg
 }
 
+func (err UnexpectedTokenError) message() string {
+	msg := fmt.Sprintf("Unexpected token \"%s\"", err.Lexeme.Value)
+
+	if len(err.Expected) > 0 {
+		msg = fmt.Sprintf("%s, expected %s", msg, oneOf(err.Expected))
+	}
+
+	return msg
+}
+
+func (err UnexpectedTokenError) Location() lex.Position {
+	return err.Lexeme.Position()
+}
+
+func (err UnexpectedTokenError) Diagnostic() lex.Diagnostic {
+	diagnostic := lex.Diagnostic{
+		Severity: lex.SeverityError,
+		Code:     "unexpected-token",
+		Message:  err.message(),
+		Span:     err.Lexeme.Span(),
+	}
+
+	if err.debug || config.Debug {
+		diagnostic.Notes = append(diagnostic.Notes, lex.Note{Message: "Debug: " + err.Debug})
+	}
+
+	return diagnostic
+}
+
 type InvalidNumberError struct {
 	UnexpectedTokenError
 }
 
 func (err InvalidNumberError) Error() string {
-	return fmt.Sprintf("Invalid number token \"%s\" at position %d", err.Lexeme.Value, err.Lexeme.Start)
+	return fmt.Sprintf("%s: Invalid number token \"%s\"", err.Lexeme.Position(), err.Lexeme.Value)
+}
+
+func (err InvalidNumberError) Diagnostic() lex.Diagnostic {
+	diagnostic := err.UnexpectedTokenError.Diagnostic()
+	diagnostic.Code = "invalid-number"
+	diagnostic.Message = fmt.Sprintf("Invalid number token \"%s\"", err.Lexeme.Value)
+
+	return diagnostic
 }
 
-var UnterminatedStatement = errors.New("Unterminated statement!")
+// A match arm value given along with the wildcard "_",
+// which would be moot as the wildcard matches anything.
+type MixedWildcardError struct {
+	UnexpectedTokenError
+}
+
+func (err MixedWildcardError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Lexeme.Position(), err.message())
+}
+
+func (err MixedWildcardError) message() string {
+	return "The wildcard \"_\" must be the only value of its match arm"
+}
+
+func (err MixedWildcardError) Diagnostic() lex.Diagnostic {
+	diagnostic := err.UnexpectedTokenError.Diagnostic()
+	diagnostic.Code = "mixed-wildcard"
+	diagnostic.Message = err.message()
+
+	return diagnostic
+}
+
+// A problem in the source that doesn't prevent
+// it from being parsed.
+type Warning struct {
+	Code    string
+	Message string
+	Notes   []lex.Note
+	position
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %s", w.location(), w.Message)
+}
+
+func (w Warning) Diagnostic() lex.Diagnostic {
+	return lex.Diagnostic{
+		Severity: lex.SeverityWarning,
+		Code:     w.Code,
+		Message:  w.Message,
+		Span:     lex.SpanAt(w.location(), 1),
+		Notes:    w.Notes,
+	}
+}
+
+// The syntax errors found by a recovering parser.
+type Errors = lex.Diagnostics
+
+var UnterminatedStatement = lex.CodedError{Code: "unterminated-statement", Message: "Unterminated statement!"}
+var IncompleteExpression = lex.CodedError{Code: "incomplete-expression", Message: "Incomplete expression!"}
+var EmptyExpression = lex.CodedError{Code: "empty-expression", Message: "Expected an expression"}
 
 func NewParser(lexer lex.Lexer) Parser {
+	return newParser(lexer, statementGrammar)
+}
+
+// Creates a parser of a single expression, such as "a + 1",
+// rather than of statements. It starts in the expression
+// section of the DFA and the expression ends at the end of
+// the input, without a semicolon. The RootNode parsed has
+// one statement, of the expression, unless there's no input.
+func NewExpressionParser(lexer lex.Lexer) Parser {
+	parser := newParser(lexer, expressionGrammar).(*parser)
+	parser.expression = true
+
+	return parser
+}
+
+// Parses a single expression from lexer, as
+// NewExpressionParser, giving the expression's node.
+func ParseExpression(lexer lex.Lexer) (Node, error) {
+	root, err := NewExpressionParser(lexer).Parse()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(root.Statements) == 0 || len(root.Statements[0].Children()) == 0 {
+		return nil, lex.Diagnosed(EmptyExpression)
+	}
+
+	return root.Statements[0].Children()[0], nil
+}
+
+func newParser(lexer lex.Lexer, grammar func() grammarMachine) Parser {
 	parser := parser{lexer: lexer, operators: NewRegister(), openedFunction: false}
 
 	parser.operators.Register("+", 0)
@@ -74,22 +208,56 @@ func NewParser(lexer lex.Lexer) Parser {
 	parser.operators.Register(">", 1)
 	parser.operators.Register("<", 1)
 	parser.operators.Register("==", 1)
+	// Member access, such as a caught error's message. It binds
+	// tighter than any other operator, and is never dispatched
+	// to an operation: only an identifier may follow it.
+	parser.operators.Register(".", 2)
 
-	machine, err := buildDfa(&parser)
+	parser.dfa = grammar().CloneFor(&parser)
 
-	if err != nil {
-		panic(fmt.Sprintf("Cannot build parse machine: %v", err))
-	}
+	return &parser
+}
 
-	parser.dfa = machine
+// Creates a parser that carries on past syntax errors,
+// skipping to the end of the statement or block they're
+// in. Parse then returns every error found as Errors,
+// along with what of the AST could be parsed.
+func NewRecoveringParser(lexer lex.Lexer) Parser {
+	parser := NewParser(lexer).(*parser)
+	parser.recovering = true
 
-	return &parser
+	return parser
+}
+
+// Makes p's syntax errors describe the route the parser
+// took to them, as config.Debug does for all parsers.
+func Debugging(p Parser) Parser {
+	if debugged, isParser := p.(*parser); isParser {
+		debugged.debug = true
+	}
+
+	return p
 }
 
+// Parses the lexer's input. Any error is a lex.Diagnostic,
+// or Errors of them if the parser is recovering.
 func (p *parser) Parse() (RootNode, error) {
+	root, err := p.parse()
+
+	if errs, isErrors := err.(Errors); isErrors {
+		return root, errs
+	}
+
+	return root, lex.Diagnosed(err)
+}
+
+func (p *parser) parse() (RootNode, error) {
 	root := &RootNode{}
 	p.ast = root
 	p.nodeStack = []ContainsChildren{}
+	p.warnings = []Warning{}
+	p.comments = []lex.Lexeme{}
+	p.errors = Errors{}
 
 	if next, eof, err := p.consume(); eof != nil {
 		return *root, nil
@@ -105,6 +273,8 @@ func (p *parser) Parse() (RootNode, error) {
 		p.next = next
 	}
 
+	var last lex.Lexeme
+
 	for {
 		if p.current.IsEmpty() {
 			break
@@ -112,47 +282,249 @@ func (p *parser) Parse() (RootNode, error) {
 
 		// We don't care about whitespace
 		if p.current.Type != lex.LWhitespace {
-			if err := p.dfa.Transition(p.current.Type.String()); err != nil {
-				if _, isInvalid := err.(dfa.InvalidMachineTransition); isInvalid {
-					return *root, UnexpectedTokenError{Lexeme: p.current, Debug: p.dfa.DebugRoute()}
+			if err := p.transition(); err != nil {
+				if !p.recovering {
+					return *root, err
 				}
 
-				return *root, err
+				p.errors = append(p.errors, lex.Diagnose(err))
+
+				if err := p.synchronise(); err != nil {
+					return *root, append(p.errors, lex.Diagnose(err))
+				}
+
+				continue
 			}
+
+			last = p.current
 		}
 
-		p.current = p.next
+		if err := p.advance(); err != nil {
+			return *root, p.fail(err)
+		}
+	}
 
-		if next, _, err := p.consume(); err != nil {
-			return *root, err
-		} else {
-			p.next = next
+	if p.expression {
+		if err := p.dfa.Transition(endOfInput); err != nil {
+			return *root, p.fail(lex.At(IncompleteExpression, last.Position()))
 		}
 	}
 
 	if err := p.dfa.Finish(); err != nil {
-		return *root, UnterminatedStatement
+		return *root, p.fail(lex.At(UnterminatedStatement, last.Position()))
+	}
+
+	if len(p.errors) > 0 {
+		return *root, p.errors
 	}
 
 	return *root, nil
 }
 
-func (p *parser) consume() (next lex.Lexeme, eof error, lexErr error) {
-	if lexeme, err := p.lexer.GetNext(); err == nil {
-		next = lexeme
-	} else if err == lex.EndOfInput {
-		eof = err
+// Moves the DFA on with the current lexeme.
+func (p *parser) transition() error {
+	via := p.symbol()
+
+	if err := p.dfa.Transition(via); err != nil {
+		if _, isInvalid := err.(dfa.InvalidMachineTransition); isInvalid {
+			return p.unexpected(p.dfa.Outgoing())
+		}
+
+		// The DFA stays where it was when a function it calls
+		// rejects the lexeme, so has what could have been given
+		// instead, other than the symbol that was rejected.
+		if unexpected, isUnexpected := err.(UnexpectedTokenError); isUnexpected {
+			if unexpected.Expected != nil {
+				return err
+			}
+
+			expected := []symbol{}
+
+			for _, outgoing := range p.dfa.Outgoing() {
+				if outgoing != via {
+					expected = append(expected, outgoing)
+				}
+			}
+
+			return p.unexpected(expected)
+		}
+
+		return lex.At(err, p.current.Position())
+	}
+
+	return nil
+}
+
+func (p *parser) unexpected(expected []symbol) UnexpectedTokenError {
+	return UnexpectedTokenError{
+		Lexeme:   p.current,
+		Expected: describeSymbols(expected),
+		Debug:    p.dfa.DebugRoute(),
+		debug:    p.debug,
+	}
+}
+
+func (p *parser) advance() error {
+	p.current = p.next
+
+	next, _, err := p.consume()
+
+	if err == nil {
+		p.next = next
+	}
+
+	return err
+}
+
+// Gets the error to stop parsing with: err alone, or
+// with those recovered from if the parser is recovering.
+func (p *parser) fail(err error) error {
+	if !p.recovering {
+		return err
+	}
+
+	return append(p.errors, lex.Diagnose(err))
+}
+
+// Recovers from a syntax error by skipping past the
+// next semicolon, or up to the next closing brace, and
+// discarding the statement being parsed. A closing
+// brace is parsed again so that it closes its block,
+// unless it is the brace we've already recovered at.
+// Parsing resumes in the state of the innermost open
+// block: in a match's arms, the bad arm is skipped.
+func (p *parser) synchronise() error {
+	if p.current == p.synchronisedAt {
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+
+	for len(p.nodeStack) > 0 && !isOpenBlock(getContext(p)) {
+		p.closeNode()
+	}
+
+	p.openedFunction = false
+
+	if _, inArms := getContext(p).(*Match); inArms {
+		if err := p.skipMatchArm(); err != nil {
+			return err
+		}
+
+		return p.dfa.ResetTo(matchArms)
+	}
+
+	for !p.current.IsEmpty() && p.current.Type != lex.LSemiColon && p.current.Type != lex.LBraceClose {
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+
+	if p.current.Type == lex.LSemiColon {
+		if err := p.advance(); err != nil {
+			return err
+		}
 	} else {
-		lexErr = err
+		p.synchronisedAt = p.current
 	}
 
-	return
+	p.dfa.Reset()
+
+	return nil
+}
+
+// Skips the rest of a match arm, past its body if it
+// has one, or up to the closing brace of the match.
+func (p *parser) skipMatchArm() error {
+	depth := 0
+
+	for !p.current.IsEmpty() {
+		switch p.current.Type {
+		case lex.LBraceOpen:
+			depth++
+		case lex.LBraceClose:
+			if depth == 0 {
+				p.synchronisedAt = p.current
+
+				return nil
+			}
+
+			depth--
+
+			if depth == 0 {
+				return p.advance()
+			}
+		}
+
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) Warnings() []Warning {
+	return p.warnings
+}
+
+func (p *parser) Comments() []lex.Lexeme {
+	return p.comments
+}
+
+// Gets the symbol to transition the DFA with for the
+// current lexeme. This is the lexeme's type, except
+// for closing braces which depend on the block they
+// close: the DFA alone can't know where a nested block
+// should return to, but our node stack can. The operator
+// "=>" has its own symbol, as it isn't used in expressions.
+func (p *parser) symbol() symbol {
+	switch p.current.Type {
+	case lex.LBraceClose:
+		switch p.innermostBlock().(type) {
+		case *MatchArm:
+			return matchArmClose
+		case *Try:
+			return tryClose
+		}
+	case lex.LOperator:
+		if p.current.Value == arrow.String() {
+			return arrow
+		}
+	}
+
+	return symbolOf(p.current.Type)
+}
+
+// Gets the next lexeme, setting aside any comments.
+func (p *parser) consume() (next lex.Lexeme, eof error, lexErr error) {
+	for {
+		lexeme, err := p.lexer.GetNext()
+
+		if err == nil && (lexeme.Type == lex.LComment || lexeme.Type == lex.LTrailingComment) {
+			p.comments = append(p.comments, lexeme)
+
+			continue
+		}
+
+		if err == nil {
+			next = lexeme
+		} else if err == lex.EndOfInput {
+			eof = err
+		} else {
+			lexErr = err
+		}
+
+		return
+	}
 }
 
 func (p *parser) createIdentifier() error {
 	if p.next.Type == lex.LParenOpen {
 		p.openedFunction = true
-		return p.push(NewFunctionCall(p.current.Value, p.current.Line, p.current.Start))
+		call := NewFunctionCall(p.current.Value, p.current.Line, p.current.Start)
+		call.Identifier.setFile(p.current.File)
+		return p.push(call)
 	} else {
 		return p.push(NewIdentifier(p.current.Value, p.current.Line, p.current.Start))
 	}
@@ -228,7 +600,11 @@ func (p *parser) closeArgument() error {
 		return UnexpectedTokenError{Lexeme: p.current}
 	}
 
-	for _, isFunctionCall := getContext(p).(*FunctionCall); len(p.nodeStack) > 0 && !isFunctionCall; {
+	for len(p.nodeStack) > 0 {
+		if _, isFunctionCall := getContext(p).(*FunctionCall); isFunctionCall {
+			break
+		}
+
 		p.closeNode()
 	}
 
@@ -283,33 +659,239 @@ func (p *parser) createIf() error {
 }
 
 // Closes all open nodes up the stack
-// until we close a statement node.
+// until we close a statement node, or
+// reach the block the statement is in.
 func (p *parser) closeStatement() error {
-	context := getContext(p)
+	for len(p.nodeStack) > 0 {
+		context := getContext(p)
+
+		if isOpenBlock(context) {
+			// Statements in a block are its direct children;
+			// there's no statement node to close.
+			break
+		}
 
-	for _, isStatement := context.(*Statement); !isStatement && len(p.nodeStack) > 1; {
-		// Close while the head of the stack isn't a statement.
 		p.closeNode()
+
+		if _, isStatement := context.(*Statement); isStatement {
+			break
+		}
 	}
 
-	// Close one more time. This closes the statement
-	p.closeNode()
+	return nil
+}
+
+// Closes all nodes up the stack until we reach the
+// node whose block is about to be opened, e.g. closing
+// an if's condition before its body.
+func (p *parser) closeBlockHeader() error {
+	for len(p.nodeStack) > 0 {
+		if _, isBlock := getContext(p).(block); isBlock {
+			break
+		}
+
+		p.closeNode()
+	}
 
 	return nil
 }
 
-// Closes all open nodes up the stack
-// until we close an if node.
-func (p *parser) closeIf() error {
-	context := getContext(p)
+// Marks the node at the head of the stack as having
+// entered its block, so following statements become
+// its children.
+func (p *parser) openBlock() error {
+	if node, isBlock := getContext(p).(block); isBlock {
+		node.openBlock()
+
+		return nil
+	}
+
+	return UnexpectedTokenError{Lexeme: p.current}
+}
+
+// Closes all nodes up to and including the innermost
+// open block, then any nodes that were waiting on that
+// block, such as the statement it was in.
+func (p *parser) closeBlock() error {
+	for len(p.nodeStack) > 0 {
+		context := getContext(p)
 
-	for _, isIf := context.(*If); !isIf && len(p.nodeStack) > 1; {
-		// Close while the head of the stack isn't a statement.
 		p.closeNode()
+
+		if isOpenBlock(context) {
+			break
+		}
 	}
 
-	// Close one more time. This closes the if
-	p.closeNode()
+	for len(p.nodeStack) > 0 && !isOpenBlock(getContext(p)) {
+		p.closeNode()
+	}
+
+	return nil
+}
+
+// Closes all nodes up to the innermost try, and closes
+// the try's own block. The try stays on the stack as
+// it must be followed by a catch or finally.
+func (p *parser) closeTryBlock() error {
+	for len(p.nodeStack) > 0 {
+		if try, isTry := getContext(p).(*Try); isTry {
+			try.closeBlock()
+
+			return nil
+		}
+
+		p.closeNode()
+	}
+
+	return nil
+}
+
+func (p *parser) createImport() error {
+	return p.push(&Import{position: position{line: p.current.Line, column: p.current.Start}})
+}
+
+func (p *parser) createExport() error {
+	return p.push(NewExport(p.current.Line, p.current.Start))
+}
+
+func (p *parser) createThrow() error {
+	return p.push(NewThrow(p.current.Line, p.current.Start))
+}
+
+func (p *parser) createTry() error {
+	return p.push(&Try{position: position{line: p.current.Line, column: p.current.Start}})
+}
+
+func (p *parser) createCatch() error {
+	return p.push(&Catch{position: position{line: p.current.Line, column: p.current.Start}})
+}
+
+// Creates a finally block. If we're not directly after
+// a try's block, this reopens the try that was closed
+// along with its catch block, if there is one.
+func (p *parser) createFinally() error {
+	if _, isTry := getContext(p).(*Try); !isTry && !p.reopenTry() {
+		return UnexpectedTokenError{Lexeme: p.current}
+	}
+
+	return p.push(&Finally{position: position{line: p.current.Line, column: p.current.Start}})
+}
+
+// Puts the most recently closed node back on to the stack
+// if it is a try that is yet to have a finally.
+func (p *parser) reopenTry() bool {
+	var last Node
+	var statement *Statement
+
+	if context := getContext(p); context == nil {
+		if len(p.ast.Statements) == 0 {
+			return false
+		}
+
+		statement = p.ast.Statements[len(p.ast.Statements)-1]
+		last = statement.getLastChild()
+	} else if adjustable, isAdjustable := context.(Adjustable); isAdjustable {
+		last = adjustable.getLastChild()
+	}
+
+	if try, isTry := last.(*Try); !isTry || try.finally != nil {
+		return false
+	} else {
+		if statement != nil {
+			p.nodeStack = append(p.nodeStack, statement)
+		}
+
+		p.nodeStack = append(p.nodeStack, try)
+	}
+
+	return true
+}
+
+func (p *parser) createMatch() error {
+	return p.push(&Match{position: position{line: p.current.Line, column: p.current.Start}})
+}
+
+// Adds a value to the current arm of a match, starting
+// a new arm if we're not in one. Only literals and the
+// wildcard "_" are allowed, and the wildcard must be an
+// arm's only value; duplicate literals are reported as
+// warnings as the later arm can never match.
+func (p *parser) createMatchArmValue() error {
+	if match, isMatch := getContext(p).(*Match); isMatch {
+		if err := p.push(&MatchArm{position: position{line: p.current.Line, column: p.current.Start}}); err != nil {
+			return err
+		}
+
+		p.warnDuplicateMatchArmValue(match)
+	} else {
+		p.warnDuplicateMatchArmValue(p.innermostMatch())
+	}
+
+	arm, isArm := getContext(p).(*MatchArm)
+	isWildcard := p.current.Type == lex.LIdentifier && p.current.Value == "_"
+
+	if isArm && (arm.wildcard || (isWildcard && len(arm.values) > 0)) {
+		return MixedWildcardError{UnexpectedTokenError{Lexeme: p.current}}
+	}
+
+	switch p.current.Type {
+	case lex.LNumber:
+		return p.createNumberLiteral()
+	case lex.LQuoted:
+		return p.createStringLiteral()
+	case lex.LBoolTrue, lex.LBoolFalse:
+		return p.createBooleanLiteral()
+	}
+
+	if isArm && isWildcard {
+		arm.wildcard = true
+
+		return nil
+	}
+
+	return UnexpectedTokenError{Lexeme: p.current}
+}
+
+func (p *parser) warnDuplicateMatchArmValue(match *Match) {
+	if match == nil {
+		return
+	}
+
+	for _, arm := range match.arms {
+		for _, value := range arm.values {
+			if isSameLiteral(value, p.current) {
+				p.warnings = append(p.warnings, Warning{
+					Code:     "duplicate-match-arm",
+					Message:  fmt.Sprintf("Duplicate match arm \"%s\"", p.current.Value),
+					Notes:    []lex.Note{{Message: "First matched here", Span: lex.SpanAt(Position(value), 1)}},
+					position: position{file: p.current.File, line: p.current.Line, column: p.current.Start},
+				})
+
+				return
+			}
+		}
+	}
+}
+
+// Gets the innermost node on the stack with an open block.
+func (p *parser) innermostBlock() ContainsChildren {
+	for i := len(p.nodeStack) - 1; i >= 0; i-- {
+		if isOpenBlock(p.nodeStack[i]) {
+			return p.nodeStack[i]
+		}
+	}
+
+	return nil
+}
+
+// Gets the innermost match on the stack.
+func (p *parser) innermostMatch() *Match {
+	for i := len(p.nodeStack) - 1; i >= 0; i-- {
+		if match, isMatch := p.nodeStack[i].(*Match); isMatch {
+			return match
+		}
+	}
 
 	return nil
 }
@@ -324,10 +906,15 @@ func (p *parser) push(node Node) error {
 	// Insert a statement if we need to.
 	if context == nil {
 		statement := NewStatement(p.current.Line, p.current.Start)
+		statement.setFile(p.current.File)
 		p.ast.PushStatement(statement)
 		p.nodeStack = append(p.nodeStack, statement)
 	}
 
+	if located, isLocatable := node.(locatable); isLocatable {
+		located.setFile(p.current.File)
+	}
+
 	if nodeContainingChildren, nodeContainsChildren := node.(ContainsChildren); nodeContainsChildren {
 		// Loop over context up the AST until we:
 		// 1. Find a context we should should replace.
@@ -375,6 +962,11 @@ func (p *parser) push(node Node) error {
 				// Hit a non-adjustable parent in the AST; stop trying to replace.
 				break
 			}
+
+			if isOpenBlock(toReplace) {
+				// Nodes can't be reshuffled out of the block they're in.
+				break
+			}
 		}
 	}
 
@@ -443,6 +1035,12 @@ func (p parser) shouldReplaceLastChildOf(replacer ContainsChildren, parent Adjus
 	}
 
 	lastChild := parent.getLastChild()
+
+	if lastChild == nil {
+		// Nothing to replace.
+		return nil
+	}
+
 	lastChildOperator, lastChildIsOperator := lastChild.(*Operator)
 
 	if lastChildIsOperator && replacerIsOperator {
@@ -503,3 +1101,81 @@ func (p parser) nodeStackContains(match func(ContainsChildren) bool) bool {
 
 	return false
 }
+
+func isOpenBlock(node ContainsChildren) bool {
+	b, isBlock := node.(block)
+
+	return isBlock && b.isOpen()
+}
+
+// Returns true if node is a literal with the same
+// type and value as lexeme.
+func isSameLiteral(node Node, lexeme lex.Lexeme) bool {
+	switch literal := node.(type) {
+	case *Number:
+		if lexeme.Type == lex.LNumber {
+			number, err := strconv.ParseFloat(lexeme.Value, 64)
+
+			return err == nil && number == literal.Value
+		}
+	case *String:
+		return lexeme.Type == lex.LQuoted && lexeme.Value == literal.Value
+	case *Boolean:
+		return (lexeme.Type == lex.LBoolTrue && literal.Value) || (lexeme.Type == lex.LBoolFalse && !literal.Value)
+	}
+
+	return false
+}
+
+// Describes the DFA symbols as the tokens they are
+// parsed from, for telling users what was expected.
+func describeSymbols(symbols []symbol) []string {
+	described := map[string]bool{}
+	descriptions := []string{}
+
+	for _, s := range symbols {
+		var description string
+
+		switch s {
+		case identifier, number, operator:
+			description = s.String()
+		case quoted:
+			description = "string"
+		case ltrue, lfalse:
+			description = "boolean"
+		case matchArmClose, tryClose, braceClose:
+			description = `"}"`
+		case term:
+			description = `";"`
+		case endOfInput:
+			description = "end of input"
+		case parenOpen:
+			description = `"("`
+		case parenClose:
+			description = `")"`
+		case braceOpen:
+			description = `"{"`
+		default:
+			// Keywords and punctuation whose types are their values.
+			description = fmt.Sprintf(`"%s"`, s)
+		}
+
+		if !described[description] {
+			described[description] = true
+			descriptions = append(descriptions, description)
+		}
+	}
+
+	sort.Strings(descriptions)
+
+	return descriptions
+}
+
+// Lists options as "a", "a or b", "a, b or c" and so on.
+func oneOf(options []string) string {
+	if len(options) == 1 {
+		return options[0]
+	}
+
+	return strings.Join(options[0:len(options)-1], ", ") + " or " + options[len(options)-1]
+}
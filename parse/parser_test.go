@@ -1,8 +1,12 @@
 package parse_test
 
 import (
+	"errors"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/ehimen/jaslang/dfa"
 	"github.com/ehimen/jaslang/lex"
 	"github.com/ehimen/jaslang/parse"
 	"github.com/ehimen/jaslang/testutil"
@@ -59,7 +63,7 @@ func TestInvalidNumberSyntax(t *testing.T) {
 
 	_, err := parser.Parse()
 
-	if _, isInvalidNumber := err.(parse.InvalidNumberError); !isInvalidNumber {
+	if !errors.As(err, &parse.InvalidNumberError{}) {
 		t.Fatalf("Expected Parse() to fail on invalid number, but got: %s", err)
 	}
 }
@@ -69,11 +73,36 @@ func TestIncompleteInput(t *testing.T) {
 		testutil.MakeLexeme("true", lex.LBoolTrue, 1, 1),
 	})
 
-	if _, err := parser.Parse(); err != parse.UnterminatedStatement {
+	if _, err := parser.Parse(); !errors.Is(err, parse.UnterminatedStatement) {
 		t.Fatalf("Expected unterminated statement error, but got: %v", err)
 	}
 }
 
+func TestErrorDiagnostic(t *testing.T) {
+	parser := getParser([]lex.Lexeme{
+		testutil.MakeLexeme("let", lex.LLet, 1, 1),
+		testutil.MakeLexeme("==", lex.LOperator, 5, 1),
+	})
+
+	_, err := parser.Parse()
+
+	var diagnostic lex.Diagnostic
+
+	if !errors.As(err, &diagnostic) {
+		t.Fatalf("Expected diagnostic, but got: %v", err)
+	}
+
+	assert.Equal(t, "unexpected-token", diagnostic.Code)
+	assert.Equal(t, "Unexpected token \"==\", expected identifier", diagnostic.Message)
+
+	var unexpectedToken parse.UnexpectedTokenError
+
+	if assert.True(t, errors.As(err, &unexpectedToken)) {
+		assert.Equal(t, []string{"identifier"}, unexpectedToken.Expected)
+	}
+	assert.Equal(t, lex.Span{Start: lex.Position{Line: 1, Column: 5}, End: lex.Position{Line: 1, Column: 7}}, diagnostic.Span)
+}
+
 func TestTrueFalse(t *testing.T) {
 	parser := getParser([]lex.Lexeme{
 		testutil.MakeLexeme("true", lex.LBoolTrue, 1, 1),
@@ -294,10 +323,12 @@ func TestInvalidLetAssigned(t *testing.T) {
 
 	_, err := parser.Parse()
 
-	if unexpectedToken, isUnexpectedToken := err.(parse.UnexpectedTokenError); !isUnexpectedToken {
+	var unexpectedToken parse.UnexpectedTokenError
+
+	if !errors.As(err, &unexpectedToken) {
 		t.Fatalf("Expected unexpected token error, but got: %v", err)
 	} else {
-		assert.Equal(t, "Unexpected token \"=\" (position 2, line 1)", unexpectedToken.Error())
+		assert.Equal(t, "1:2: Unexpected token \"=\", expected identifier", unexpectedToken.Error())
 	}
 }
 
@@ -311,10 +342,12 @@ func TestInvalidLetWithoutType(t *testing.T) {
 
 	_, err := parser.Parse()
 
-	if unexpectedToken, isUnexpectedToken := err.(parse.UnexpectedTokenError); !isUnexpectedToken {
+	var unexpectedToken parse.UnexpectedTokenError
+
+	if !errors.As(err, &unexpectedToken) {
 		t.Fatalf("Expected unexpected token error, but got: %v", err)
 	} else {
-		assert.Equal(t, "Unexpected token \"=\" (position 3, line 1)", unexpectedToken.Error())
+		assert.Equal(t, "1:3: Unexpected token \"=\", expected identifier", unexpectedToken.Error())
 	}
 }
 
@@ -329,13 +362,293 @@ func TestInvalidNestedLet(t *testing.T) {
 
 	_, err := parser.Parse()
 
-	if unexpectedToken, isUnexpectedToken := err.(parse.UnexpectedTokenError); !isUnexpectedToken {
+	var unexpectedToken parse.UnexpectedTokenError
+
+	if !errors.As(err, &unexpectedToken) {
 		t.Fatalf("Expected unexpected token error, but got: %v", err)
 	} else {
-		assert.Equal(t, "Unexpected token \"let\" (position 5, line 1)", unexpectedToken.Error())
+		assert.Equal(t, "1:5: Unexpected token \"let\", expected \")\", boolean, identifier, number, operator or string", unexpectedToken.Error())
+	}
+}
+
+func TestMatch(t *testing.T) {
+	parser := getParser([]lex.Lexeme{
+		testutil.MakeLexeme("match", lex.LMatch, 1, 1),
+		testutil.MakeLexeme("(", lex.LParenOpen, 2, 1),
+		testutil.MakeLexeme("a", lex.LIdentifier, 3, 1),
+		testutil.MakeLexeme(")", lex.LParenClose, 4, 1),
+		testutil.MakeLexeme("{", lex.LBraceOpen, 5, 1),
+		testutil.MakeLexeme("1", lex.LNumber, 6, 1),
+		testutil.MakeLexeme(",", lex.LComma, 7, 1),
+		testutil.MakeLexeme("2", lex.LNumber, 8, 1),
+		testutil.MakeLexeme("=>", lex.LOperator, 9, 1),
+		testutil.MakeLexeme("{", lex.LBraceOpen, 10, 1),
+		testutil.MakeLexeme("b", lex.LIdentifier, 11, 1),
+		testutil.MakeLexeme(";", lex.LSemiColon, 12, 1),
+		testutil.MakeLexeme("}", lex.LBraceClose, 13, 1),
+		testutil.MakeLexeme("_", lex.LIdentifier, 14, 1),
+		testutil.MakeLexeme("=>", lex.LOperator, 15, 1),
+		testutil.MakeLexeme("{", lex.LBraceOpen, 16, 1),
+		testutil.MakeLexeme("}", lex.LBraceClose, 17, 1),
+		testutil.MakeLexeme("}", lex.LBraceClose, 18, 1),
+		testutil.MakeLexeme("c", lex.LIdentifier, 19, 1),
+		testutil.MakeLexeme(";", lex.LSemiColon, 20, 1),
+	})
+
+	expected := expectStatements(
+		parse.NewStatement(
+			1,
+			1,
+			parse.NewMatch(
+				parse.NewIdentifier("a", 1, 3),
+				1,
+				1,
+				parse.NewMatchArm(
+					[]parse.Node{parse.NewNumber(1, 1, 6), parse.NewNumber(2, 1, 8)},
+					false,
+					1,
+					6,
+					parse.NewIdentifier("b", 1, 11),
+				),
+				parse.NewMatchArm(nil, true, 1, 14),
+			),
+		),
+		parse.NewStatement(1, 19, parse.NewIdentifier("c", 1, 19)),
+	)
+
+	assert.Equal(t, expected, testParse(parser, t))
+	assert.Empty(t, parser.Warnings())
+}
+
+func TestMatchDuplicateArmWarning(t *testing.T) {
+	parser := getParser([]lex.Lexeme{
+		testutil.MakeLexeme("match", lex.LMatch, 1, 1),
+		testutil.MakeLexeme("(", lex.LParenOpen, 2, 1),
+		testutil.MakeLexeme("a", lex.LIdentifier, 3, 1),
+		testutil.MakeLexeme(")", lex.LParenClose, 4, 1),
+		testutil.MakeLexeme("{", lex.LBraceOpen, 5, 1),
+		testutil.MakeLexeme("x", lex.LQuoted, 6, 1),
+		testutil.MakeLexeme("=>", lex.LOperator, 7, 1),
+		testutil.MakeLexeme("{", lex.LBraceOpen, 8, 1),
+		testutil.MakeLexeme("}", lex.LBraceClose, 9, 1),
+		testutil.MakeLexeme("y", lex.LQuoted, 1, 2),
+		testutil.MakeLexeme(",", lex.LComma, 2, 2),
+		testutil.MakeLexeme("x", lex.LQuoted, 3, 2),
+		testutil.MakeLexeme("=>", lex.LOperator, 4, 2),
+		testutil.MakeLexeme("{", lex.LBraceOpen, 5, 2),
+		testutil.MakeLexeme("}", lex.LBraceClose, 6, 2),
+		testutil.MakeLexeme("}", lex.LBraceClose, 7, 2),
+	})
+
+	testParse(parser, t)
+
+	if warnings := parser.Warnings(); assert.Len(t, warnings, 1) {
+		assert.Equal(t, "2:3: Duplicate match arm \"x\"", warnings[0].String())
+
+		diagnostic := warnings[0].Diagnostic()
+
+		assert.Equal(t, lex.SeverityWarning, diagnostic.Severity)
+		assert.Equal(t, "duplicate-match-arm", diagnostic.Code)
+		assert.Equal(t, []lex.Note{{Message: "First matched here", Span: lex.SpanAt(lex.Position{Line: 1, Column: 6}, 1)}}, diagnostic.Notes)
 	}
 }
 
+func TestTryCatchFinally(t *testing.T) {
+	parser := getParser([]lex.Lexeme{
+		testutil.MakeLexeme("try", lex.LTry, 1, 1),
+		testutil.MakeLexeme("{", lex.LBraceOpen, 2, 1),
+		testutil.MakeLexeme("throw", lex.LThrow, 3, 1),
+		testutil.MakeLexeme("a", lex.LIdentifier, 4, 1),
+		testutil.MakeLexeme(";", lex.LSemiColon, 5, 1),
+		testutil.MakeLexeme("}", lex.LBraceClose, 6, 1),
+		testutil.MakeLexeme("catch", lex.LCatch, 7, 1),
+		testutil.MakeLexeme("(", lex.LParenOpen, 8, 1),
+		testutil.MakeLexeme("e", lex.LIdentifier, 9, 1),
+		testutil.MakeLexeme(")", lex.LParenClose, 10, 1),
+		testutil.MakeLexeme("{", lex.LBraceOpen, 11, 1),
+		testutil.MakeLexeme("b", lex.LIdentifier, 12, 1),
+		testutil.MakeLexeme(";", lex.LSemiColon, 13, 1),
+		testutil.MakeLexeme("}", lex.LBraceClose, 14, 1),
+		testutil.MakeLexeme("finally", lex.LFinally, 15, 1),
+		testutil.MakeLexeme("{", lex.LBraceOpen, 16, 1),
+		testutil.MakeLexeme("c", lex.LIdentifier, 17, 1),
+		testutil.MakeLexeme(";", lex.LSemiColon, 18, 1),
+		testutil.MakeLexeme("}", lex.LBraceClose, 19, 1),
+	})
+
+	expected := expectStatements(
+		parse.NewStatement(
+			1,
+			1,
+			parse.NewTry(
+				1,
+				1,
+				parse.NewCatch(parse.NewIdentifier("e", 1, 9), 1, 7, parse.NewIdentifier("b", 1, 12)),
+				parse.NewFinally(1, 15, parse.NewIdentifier("c", 1, 17)),
+				parse.NewThrow(1, 3, parse.NewIdentifier("a", 1, 4)),
+			),
+		),
+	)
+
+	assert.Equal(t, expected, testParse(parser, t))
+}
+
+func TestRecoveringParser(t *testing.T) {
+	parser := parse.NewRecoveringParser(lex.NewJslLexer(strings.NewReader(`let a number = ;
+println("kept");
+if (true) {
+    let b = 2;
+    println("also kept");
+}
+let c = 3;`)))
+
+	ast, err := parser.Parse()
+
+	var errs parse.Errors
+
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected parse errors, but got: %v", err)
+	}
+
+	assert.Equal(t, "1:16: Unexpected token \";\", expected \")\", boolean, identifier, number, operator or string\n"+
+		"4:11: Unexpected token \"=\", expected identifier\n"+
+		"7:7: Unexpected token \"=\", expected identifier", errs.Error())
+	assert.Equal(t, "unexpected-token", errs[1].Code)
+
+	var unexpectedToken parse.UnexpectedTokenError
+
+	assert.True(t, errors.As(err, &unexpectedToken))
+
+	if assert.Len(t, ast.Statements, 4) {
+		ifStatement := ast.Statements[2].Children()[0].(*parse.If)
+
+		// The partial let is kept, followed by the statement after it.
+		assert.Len(t, ifStatement.Children(), 2)
+	}
+}
+
+func TestRecoveringParserInMatch(t *testing.T) {
+	parser := parse.NewRecoveringParser(lex.NewJslLexer(strings.NewReader(`match (1) {
+    foo => { println("a"); }
+    2 => { let = 2; }
+    3 + 4 => { println("b"); }
+    5 => { println("c"); }
+}
+println("kept");`)))
+
+	ast, err := parser.Parse()
+
+	var errs parse.Errors
+
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected parse errors, but got: %v", err)
+	}
+
+	// Each mistake is reported once; the arms after
+	// them are parsed as arms, not as statements.
+	assert.Equal(t, "2:5: Unexpected token \"foo\", expected \"}\", boolean, number or string\n"+
+		"3:16: Unexpected token \"=\", expected identifier\n"+
+		"4:7: Unexpected token \"+\", expected \",\" or \"=>\"", errs.Error())
+
+	if assert.Len(t, ast.Statements, 2) {
+		match := ast.Statements[0].Children()[0].(*parse.Match)
+		last := match.Arms()[len(match.Arms())-1]
+
+		assert.Len(t, last.Values(), 1)
+		assert.Len(t, last.Children(), 1)
+	}
+}
+
+func TestRecoveringParserWithoutErrors(t *testing.T) {
+	parser := parse.NewRecoveringParser(lex.NewJslLexer(strings.NewReader(`println("a");`)))
+
+	if ast, err := parser.Parse(); assert.Nil(t, err) {
+		assert.Len(t, ast.Statements, 1)
+	}
+}
+
+func TestComments(t *testing.T) {
+	parser := parse.NewParser(lex.NewJslLexer(strings.NewReader(`// first
+println("a"); // second`)))
+
+	if ast, err := parser.Parse(); assert.Nil(t, err) {
+		assert.Len(t, ast.Statements, 1)
+	}
+
+	if comments := parser.Comments(); assert.Len(t, comments, 2) {
+		assert.Equal(t, "// first", comments[0].Value)
+		assert.Equal(t, 2, comments[1].Line)
+	}
+}
+
+func TestParseExpression(t *testing.T) {
+	node, err := parse.ParseExpression(testutil.NewSimpleLexer([]lex.Lexeme{
+		testutil.MakeLexeme("a", lex.LIdentifier, 1, 1),
+		testutil.MakeLexeme("+", lex.LOperator, 2, 1),
+		testutil.MakeLexeme("b", lex.LIdentifier, 3, 1),
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, parse.NewOperator(
+		"+",
+		1,
+		2,
+		parse.NewIdentifier("a", 1, 1),
+		parse.NewIdentifier("b", 1, 3),
+	), node)
+}
+
+func TestParseExpressionStartingWithGroup(t *testing.T) {
+	node, err := parse.ParseExpression(lex.NewJslLexer(strings.NewReader(`(a + 1) * 2`)))
+
+	if assert.Nil(t, err) {
+		operator, isOperator := node.(*parse.Operator)
+
+		if assert.True(t, isOperator) && assert.Len(t, operator.Children(), 2) {
+			assert.Equal(t, "*", operator.Operator)
+			assert.IsType(t, &parse.Group{}, operator.Children()[0])
+		}
+	}
+}
+
+func TestInvalidExpressions(t *testing.T) {
+	for code, expected := range map[string]string{
+		`a + 1;`: `1:6: Unexpected token ";", expected ")", ",", end of input or operator`,
+		`a +`:    `1:3: Incomplete expression!`,
+		`let a`:  `1:1: Unexpected token "let", expected "(", ")", boolean, identifier, number, operator or string`,
+		``:       `Expected an expression`,
+	} {
+		_, err := parse.ParseExpression(lex.NewJslLexer(strings.NewReader(code)))
+
+		if assert.Error(t, err, code) {
+			assert.Equal(t, expected, err.Error(), code)
+		}
+	}
+}
+
+func TestParsersConcurrently(t *testing.T) {
+	wait := sync.WaitGroup{}
+
+	for i := 0; i < 10; i++ {
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+
+			ast, err := parse.NewParser(lex.NewJslLexer(strings.NewReader(`let a number = 1 + 2; println(a);`))).Parse()
+
+			assert.Nil(t, err)
+			assert.Len(t, ast.Statements, 2)
+
+			_, err = parse.NewParser(lex.NewJslLexer(strings.NewReader(`let a number = ;`))).Parse()
+
+			assert.Error(t, err)
+		}()
+	}
+
+	wait.Wait()
+}
+
 func getParser(lexemes []lex.Lexeme) parse.Parser {
 	return parse.NewParser(testutil.NewSimpleLexer(lexemes))
 }
@@ -357,3 +670,53 @@ func testParse(p parse.Parser, t *testing.T) parse.RootNode {
 
 	return node
 }
+
+func TestGrammarIsDescribed(t *testing.T) {
+	for _, grammar := range []dfa.Description{parse.Grammar(), parse.ExpressionGrammar()} {
+		assert.Equal(t, "start", grammar.Start)
+
+		hooks := []string{}
+
+		for _, state := range grammar.States {
+			hooks = append(hooks, state.Hooks...)
+		}
+
+		assert.Contains(t, hooks, "createIdentifier")
+		assert.Contains(t, grammar.DOT(), `"" -> "start";`)
+	}
+}
+
+func TestGrammarStatesAreNamed(t *testing.T) {
+	for _, grammar := range []dfa.Description{parse.Grammar(), parse.ExpressionGrammar()} {
+		named := map[string]bool{}
+
+		for _, state := range grammar.States {
+			assert.NotEmpty(t, state.Name)
+			assert.False(t, named[state.Name], "State %s is named twice", state.Name)
+
+			named[state.Name] = true
+		}
+	}
+}
+
+func TestLexemeTypesHaveTheirSymbols(t *testing.T) {
+	assert.Empty(t, parse.MismatchedSymbolTypes())
+}
+
+func TestGrammarIsValid(t *testing.T) {
+	for name, machine := range parse.Machines {
+		assert.Nil(t, machine().Validate(), name)
+	}
+}
+
+func TestUnexpectedQuotedTokenSpansQuotes(t *testing.T) {
+	_, err := parse.NewParser(lex.NewJslLexer(strings.NewReader(`"a" "h\"i";`))).Parse()
+
+	if assert.Error(t, err) {
+		diagnostic := lex.Diagnose(err)
+
+		assert.Equal(t, 5, diagnostic.Start.Column)
+		assert.Equal(t, 11, diagnostic.End.Column)
+		assert.Equal(t, 6, diagnostic.Length())
+	}
+}
@@ -2,6 +2,8 @@ package parse
 
 import (
 	"fmt"
+
+	"github.com/ehimen/jaslang/lex"
 )
 
 type Register struct {
@@ -16,6 +18,10 @@ func (err UnknownOperatorError) Error() string {
 	return fmt.Sprintf("Unknown operator: %s", err.operator)
 }
 
+func (err UnknownOperatorError) Diagnostic() lex.Diagnostic {
+	return lex.Diagnostic{Severity: lex.SeverityError, Code: "unknown-operator", Message: err.Error()}
+}
+
 func NewRegister() *Register {
 	return &Register{operations: make(map[string]int)}
 }
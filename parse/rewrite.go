@@ -0,0 +1,140 @@
+package parse
+
+// Implemented by nodes with children that a rewrite
+// can replace, giving where each child is kept.
+type rewritable interface {
+	slots() []*Node
+}
+
+// Rewrites the tree under node from the leaves up, replacing
+// each node with what rewrite gives for it once its children
+// have been rewritten. Nodes are changed in place and the
+// rewritten node is returned. Statements of the root, a match's
+// arms and a try's catch and finally are rewritten within, but
+// can't themselves be replaced.
+func Rewrite(node Node, rewrite func(Node) Node) Node {
+	type visit struct {
+		slot    *Node
+		rewrite bool
+	}
+
+	// As evaluation, this doesn't recurse so that deeply
+	// nested code can be rewritten.
+	stack := []visit{{slot: &node}}
+
+	for len(stack) > 0 {
+		next := stack[len(stack)-1]
+		stack = stack[0 : len(stack)-1]
+
+		if next.rewrite {
+			*next.slot = rewrite(*next.slot)
+
+			continue
+		}
+
+		stack = append(stack, visit{slot: next.slot, rewrite: true})
+
+		children := slots(*next.slot)
+
+		for i := len(children) - 1; i >= 0; i-- {
+			stack = append(stack, visit{slot: children[i]})
+		}
+	}
+
+	return node
+}
+
+func slots(node Node) []*Node {
+	if root, isRoot := node.(RootNode); isRoot {
+		statements := []*Node{}
+
+		for _, statement := range root.Statements {
+			statements = append(statements, fixed(statement))
+		}
+
+		return statements
+	}
+
+	if parent, isRewritable := node.(rewritable); isRewritable {
+		return parent.slots()
+	}
+
+	return nil
+}
+
+// A place for a child that can't be replaced.
+func fixed(node Node) *Node {
+	return &node
+}
+
+func (parent *ParentNode) slots() []*Node {
+	slots := []*Node{}
+
+	for i := range parent.children {
+		slots = append(slots, &parent.children[i])
+	}
+
+	return slots
+}
+
+func (let *Let) slots() []*Node {
+	slots := []*Node{}
+
+	for i := range let.children {
+		slots = append(slots, &let.children[i])
+	}
+
+	return slots
+}
+
+func (i *If) slots() []*Node {
+	return append([]*Node{&i.condition}, i.ParentNode.slots()...)
+}
+
+func (m *Match) slots() []*Node {
+	slots := []*Node{&m.subject}
+
+	for _, arm := range m.arms {
+		slots = append(slots, fixed(arm))
+	}
+
+	return slots
+}
+
+func (arm *MatchArm) slots() []*Node {
+	slots := []*Node{}
+
+	for i := range arm.values {
+		slots = append(slots, &arm.values[i])
+	}
+
+	return append(slots, arm.ParentNode.slots()...)
+}
+
+func (t *Try) slots() []*Node {
+	slots := t.ParentNode.slots()
+
+	if t.catch != nil {
+		slots = append(slots, fixed(t.catch))
+	}
+
+	if t.finally != nil {
+		slots = append(slots, fixed(t.finally))
+	}
+
+	return slots
+}
+
+// Gives node the position of at, including its file, as
+// if it had been parsed there. Returns node.
+func MoveTo(node Node, at Node) Node {
+	if movable, isMovable := node.(interface{ moveTo(position) }); isMovable {
+		movable.moveTo(position{file: at.File(), line: at.Line(), column: at.Column()})
+	}
+
+	return node
+}
+
+func (p *position) moveTo(at position) {
+	*p = at
+}
@@ -0,0 +1,45 @@
+package parse_test
+
+import (
+	"testing"
+
+	"github.com/ehimen/jaslang/parse"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewrite(t *testing.T) {
+	root := expectStatements(
+		parse.NewStatement(1, 1, parse.NewFunctionCall("println", 1, 1, parse.NewNumber(1, 1, 9))),
+		parse.NewStatement(2, 1, parse.NewMatch(
+			parse.NewNumber(2, 2, 8),
+			2,
+			1,
+			parse.NewMatchArm([]parse.Node{parse.NewNumber(3, 2, 13)}, false, 2, 13, parse.NewNumber(4, 2, 20)),
+		)),
+	)
+
+	visited := []float64{}
+
+	parse.Rewrite(root, func(node parse.Node) parse.Node {
+		if number, isNumber := node.(*parse.Number); isNumber {
+			visited = append(visited, number.Value)
+
+			return parse.MoveTo(parse.NewString("replaced", 0, 0), number)
+		}
+
+		return node
+	})
+
+	expected := expectStatements(
+		parse.NewStatement(1, 1, parse.NewFunctionCall("println", 1, 1, parse.NewString("replaced", 1, 9))),
+		parse.NewStatement(2, 1, parse.NewMatch(
+			parse.NewString("replaced", 2, 8),
+			2,
+			1,
+			parse.NewMatchArm([]parse.Node{parse.NewString("replaced", 2, 13)}, false, 2, 13, parse.NewString("replaced", 2, 20)),
+		)),
+	)
+
+	assert.Equal(t, []float64{1, 2, 3, 4}, visited)
+	assert.Equal(t, expected, root)
+}
@@ -0,0 +1,120 @@
+package run_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/parse"
+	"github.com/ehimen/jaslang/run"
+	"github.com/ehimen/jaslang/runtime"
+)
+
+// A long, CPU-bound script of the kind produced by generators.
+func benchmarkScript(statements int) string {
+	code := []string{`let total number = 0;
+let label string = "none";
+let even boolean = true;`}
+
+	for i := 0; i < statements; i++ {
+		code = append(code, fmt.Sprintf(`total = total + %d * 2 / 2 - 1;
+label = "row " + "%d";
+even = even == true && total == %d;
+match (label) { "row 1", "row 2" => { total = total + 1; } _ => { } }`, i, i, i))
+	}
+
+	return strings.Join(code, "\n")
+}
+
+func BenchmarkEvaluate(b *testing.B) {
+	ast := parseBenchmark(b)
+
+	for i := 0; i < b.N; i++ {
+		if err := runtime.NewEvaluator(strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{}).Evaluate(ast); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompileAndRun(b *testing.B) {
+	ast := parseBenchmark(b)
+
+	for i := 0; i < b.N; i++ {
+		if err := runtime.NewMachine(strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{}).Evaluate(ast); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRun(b *testing.B) {
+	code := runtime.Compile(parseBenchmark(b))
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := runtime.NewMachine(strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{}).Run(code); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Parses and runs a short script every time, as a host that
+// doesn't keep Programs would, to compare with BenchmarkProgram.
+func BenchmarkInterpreterRun(b *testing.B) {
+	code := benchmarkScript(10)
+
+	for _, compiled := range []bool{false, true} {
+		b.Run(engine(compiled), func(b *testing.B) {
+			interpreter := run.NewInterpreter(run.Options{Compiled: compiled})
+
+			for i := 0; i < b.N; i++ {
+				if _, err := interpreter.Run(context.Background(), strings.NewReader(code)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkProgram(b *testing.B) {
+	for _, compiled := range []bool{false, true} {
+		b.Run(engine(compiled), func(b *testing.B) {
+			program, err := run.NewInterpreter(run.Options{Compiled: compiled}).Compile(strings.NewReader(benchmarkScript(10)))
+
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := program.Run(context.Background(), run.Environment{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func engine(compiled bool) string {
+	if compiled {
+		return "compiled"
+	}
+
+	return "evaluated"
+}
+
+func parseBenchmark(b *testing.B) parse.RootNode {
+	ast, err := parse.NewParser(lex.NewJslLexer(strings.NewReader(benchmarkScript(1000)))).Parse()
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+
+	return ast
+}
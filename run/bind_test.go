@@ -0,0 +1,173 @@
+package run_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/run"
+	"github.com/ehimen/jaslang/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func bound(t *testing.T, code string, natives map[string]runtime.Invokable) ([]string, []map[string]runtime.Value) {
+	outputs := []string{}
+	globals := []map[string]runtime.Value{}
+
+	for _, compiled := range []bool{false, true} {
+		output := bytes.NewBufferString("")
+		interpreter := run.NewInterpreter(run.Options{Compiled: compiled, Output: output, Natives: natives})
+
+		result, err := interpreter.Run(context.Background(), strings.NewReader(code))
+
+		if err != nil {
+			output.WriteString(err.Error())
+		}
+
+		outputs = append(outputs, output.String())
+		globals = append(globals, result.Globals)
+	}
+
+	return outputs, globals
+}
+
+func TestBind(t *testing.T) {
+	var seen []string
+
+	natives := map[string]runtime.Invokable{
+		"repeat": runtime.MustBind("repeat", func(count int, s string) string {
+			return strings.Repeat(s, count)
+		}),
+		"check": runtime.MustBind("check", func(n float64, s string) (bool, error) {
+			if n < 0 {
+				return false, errors.New("negative")
+			}
+
+			return len(s) == int(n), nil
+		}),
+		"see": runtime.MustBind("see", func(context *runtime.Context, value runtime.Value) {
+			seen = append(seen, value.String())
+		}),
+	}
+
+	outputs, globals := bound(t, `let r boolean = check(1, "x");
+let s string = repeat(2, "ab");
+see(s);
+try { check(-1, "x"); } catch (e) { println(e.message); }`, natives)
+
+	for i := range outputs {
+		assert.Equal(t, "negative\n", outputs[i])
+		// The caught error is only in scope in its catch block.
+		assert.Equal(t, map[string]runtime.Value{
+			"r": runtime.Boolean{Value: true},
+			"s": runtime.String{Value: "abab"},
+		}, globals[i])
+	}
+
+	assert.Equal(t, []string{"abab", "abab"}, seen)
+}
+
+func TestBoundResultsMarshalled(t *testing.T) {
+	type person struct {
+		Name string `jsl:"name"`
+		Age  int    `jsl:"age"`
+	}
+
+	natives := map[string]runtime.Invokable{
+		"names": runtime.MustBind("names", func(name string) []string { return []string{name, name + "b"} }),
+		"ages":  runtime.MustBind("ages", func(name string) map[string]int { return map[string]int{name: 1} }),
+		"find":  runtime.MustBind("find", func(name string) person { return person{Name: name, Age: 3} }),
+	}
+
+	outputs, _ := bound(t, `println(names("a"));
+println(ages("a"));
+println(find("c"));`, natives)
+
+	for _, output := range outputs {
+		assert.Equal(t, "[a, ab]\n{a: 1.000}\n{age: 3.000, name: c}\n", output)
+	}
+}
+
+func TestBoundResultContainingItself(t *testing.T) {
+	type node struct {
+		Next *node `jsl:"next"`
+	}
+
+	natives := map[string]runtime.Invokable{
+		"loop": runtime.MustBind("loop", func(name string) *node {
+			n := &node{}
+			n.Next = n
+
+			return n
+		}),
+	}
+
+	outputs, _ := bound(t, `loop("a");`, natives)
+
+	for _, output := range outputs {
+		assert.Equal(t, "1:1: Cannot marshal *run_test.node, as it contains itself", output)
+	}
+}
+
+func TestBoundArgumentsChecked(t *testing.T) {
+	natives := map[string]runtime.Invokable{
+		"byte": runtime.MustBind("byte", func(b uint8) int { return int(b) }),
+	}
+
+	for code, expected := range map[string]string{
+		`byte(1, "a");`:       "1:1: byte expects 1 arguments, got 2",
+		`byte("a");`:          "1:1: Argument 1 of byte must be number, not string",
+		`byte(1.5);`:          "1:1: Argument 1 of byte must be a whole number in range, not 1.500",
+		`byte(256);`:          "1:1: Argument 1 of byte must be a whole number in range, not 256.000",
+		`byte(0 - 1);`:        "1:1: Argument 1 of byte must be a whole number in range, not -1.000",
+		`println(byte(255));`: "",
+	} {
+		outputs, _ := bound(t, code, natives)
+
+		for _, output := range outputs {
+			if expected == "" {
+				assert.Equal(t, "255.000\n", output, code)
+			} else {
+				assert.Equal(t, expected, output, code)
+			}
+		}
+	}
+}
+
+func TestBoundPanicRecovered(t *testing.T) {
+	natives := map[string]runtime.Invokable{
+		"explode": runtime.MustBind("explode", func(reason string) { panic(reason) }),
+	}
+
+	outputs, _ := bound(t, `try { explode("boom"); } catch (e) { println(e.message); }
+explode("boom");`, natives)
+
+	for _, output := range outputs {
+		assert.Equal(t, "explode panicked: boom\n2:1: explode panicked: boom", output)
+	}
+}
+
+func TestInvalidBindings(t *testing.T) {
+	for expected, fn := range map[string]interface{}{
+		"Cannot bind f: int is not a func":                          1,
+		"Cannot bind f: variadic funcs are not supported":           func(...string) {},
+		"Cannot bind f: parameter 1 has unsupported type []string":  func([]string) {},
+		"Cannot bind f: result has unsupported type map[int]string": func() map[int]string { return nil },
+		"Cannot bind f: result has unsupported type []chan int":     func() []chan int { return nil },
+		"Cannot bind f: funcs may return at most one value and an error": func() (int, int) {
+			return 0, 0
+		},
+	} {
+		_, err := runtime.Bind("f", fn)
+
+		if assert.Error(t, err) {
+			assert.Equal(t, expected, err.Error())
+			assert.Equal(t, "invalid-binding", lex.Diagnose(err).Code)
+		}
+	}
+
+	assert.Panics(t, func() { runtime.MustBind("f", 1) })
+}
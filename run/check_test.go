@@ -0,0 +1,127 @@
+package run_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/parse"
+	"github.com/ehimen/jaslang/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckReportsAllErrors(t *testing.T) {
+	err := check(t, `let a number = 1;
+println("x" + a);
+let b string = a;
+if (a) { println(c); }
+let d foo = 1;
+b = a + 2;`)
+
+	assert.Equal(t, `2:13: Unknown operator + with operands (string, number)
+3:5: Invalid value for "b". Value of type number is not of expected type string
+4:1: If condition must evaluate to boolean
+4:18: Unknown identifier: c
+5:7: Unknown type: foo
+6:1: Invalid value for "b". Value of type number is not of expected type string`, err.Error())
+}
+
+func TestCheckValidCode(t *testing.T) {
+	err := check(t, `let a number = 1 + 2 * 3;
+let b boolean = a > 1 && true;
+let s string = "a" + "b";
+if (b) { println(s, a); }
+match (s) { "ab", "cd" => { a = 2; } _ => { } }
+try { throw "oops"; } catch (e) { s = e.message; a = e.line; }`)
+
+	assert.Nil(t, err)
+}
+
+func TestCheckErrorsAreDiagnostics(t *testing.T) {
+	err := check(t, `println(missing);`)
+
+	var diagnostics lex.Diagnostics
+
+	if assert.ErrorAs(t, err, &diagnostics) && assert.Len(t, diagnostics, 1) {
+		assert.Equal(t, "unknown-identifier", diagnostics[0].Code)
+		assert.Equal(t, 7, diagnostics[0].Length())
+	}
+}
+
+func TestCheckOperatorSpans(t *testing.T) {
+	err := check(t, `let n number = 1;
+match (n) { "ab", true => { } }
+match ("a") { 12.5 => { } }
+println(n + "x");`)
+
+	var diagnostics lex.Diagnostics
+
+	if assert.ErrorAs(t, err, &diagnostics) && assert.Len(t, diagnostics, 4) {
+		for i, expected := range []struct{ column, length int }{{13, 4}, {19, 4}, {15, 4}, {11, 1}} {
+			assert.Equal(t, expected.column, diagnostics[i].Start.Column)
+			assert.Equal(t, expected.length, diagnostics[i].Length())
+		}
+	}
+}
+
+func TestCheckUnknownTypeSpan(t *testing.T) {
+	err := check(t, `let n numbers = 1;`)
+
+	var diagnostics lex.Diagnostics
+
+	if assert.ErrorAs(t, err, &diagnostics) && assert.Len(t, diagnostics, 1) {
+		assert.Equal(t, "unknown-type", diagnostics[0].Code)
+		assert.Equal(t, 7, diagnostics[0].Start.Column)
+		assert.Equal(t, 7, diagnostics[0].Length())
+	}
+}
+
+func TestCheckMembers(t *testing.T) {
+	err := check(t, `import "lib.jsl" as lib;
+println(lib.anything + 1);
+let n number = 1;
+try { throw "a"; } catch (e) { println(e.message + 1, e.nope, n.x); }`)
+
+	assert.Equal(t, `4:50: Unknown operator + with operands (string, number)
+4:57: Unknown member nope of error
+4:65: Unknown member x of number`, err.Error())
+}
+
+func TestCheckCatchIdentifierDeclared(t *testing.T) {
+	err := check(t, `let e number = 1;
+try { throw "a"; } catch (e) { }`)
+
+	assert.Equal(t, `2:27: Cannot catch into "e", as it's already declared`, err.Error())
+}
+
+func TestCheckCatchIdentifierScope(t *testing.T) {
+	err := check(t, `try { throw "a"; } catch (e) { println(e.message); }
+println(e);
+let e number = 1;`)
+
+	assert.Equal(t, `2:9: Unknown identifier: e`, err.Error())
+}
+
+func TestCheckLetRedeclared(t *testing.T) {
+	err := check(t, `let a number = 1;
+let a string = "a";
+let println number;`)
+
+	var diagnostics lex.Diagnostics
+
+	if assert.ErrorAs(t, err, &diagnostics) && assert.Len(t, diagnostics, 2) {
+		assert.Equal(t, `2:5: Cannot declare symbol "a"`+"\n"+`3:5: Cannot declare symbol "println"`, err.Error())
+		assert.Equal(t, "redeclared-symbol", diagnostics[0].Code)
+		assert.Equal(t, 7, diagnostics[1].Length())
+	}
+}
+
+func check(t *testing.T, code string) error {
+	ast, err := parse.NewParser(lex.NewJslLexer(strings.NewReader(code))).Parse()
+
+	if err != nil {
+		t.Fatalf("Unexpected Parse() error: %v", err)
+	}
+
+	return runtime.Check(ast, runtime.NewBuiltinTable())
+}
@@ -0,0 +1,91 @@
+package run_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/parse"
+	"github.com/ehimen/jaslang/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompile(t *testing.T) {
+	code := compile(t, `let a number = 1 + 2;
+if (a == 3) { println(a); }`)
+
+	assert.Equal(t, `   0 constant      1.000
+   1 constant      2.000
+   2 operator      + 2
+   3 let           a number
+   4 get           a
+   5 constant      3.000
+   6 operator      == 2
+   7 jump-if-false 11
+   8 get           a
+   9 call          println 1
+  10 pop`, code.String())
+}
+
+func TestCompileTry(t *testing.T) {
+	code := compile(t, `try { throw "a"; } catch (e) { println(e); } finally { println("done"); }`)
+
+	assert.Equal(t, `   0 try           5
+   1 constant      a
+   2 throw
+   3 end-try
+   4 jump          10
+   5 catch         e 11
+   6 get           e
+   7 call          println 1
+   8 pop
+   9 end-try
+  10 no-error
+  11 constant      done
+  12 call          println 1
+  13 pop
+  14 end-finally`, code.String())
+}
+
+func TestRunCompiledManyTimes(t *testing.T) {
+	code := compile(t, `let a number = 1;
+try { throw "oops"; } finally { println(a); }`)
+
+	for i := 0; i < 2; i++ {
+		output := bytes.NewBufferString("")
+		err := runtime.NewMachine(strings.NewReader(""), output, &bytes.Buffer{}).Run(code)
+
+		assert.Equal(t, "2:7: oops", err.Error())
+		assert.Equal(t, "1.000\n", output.String())
+	}
+}
+
+func TestNestedTryCompiled(t *testing.T) {
+	output := bytes.NewBufferString("")
+	err := runtime.NewMachine(strings.NewReader(""), output, &bytes.Buffer{}).Evaluate(parseCode(t, `try {
+    try { throw "inner"; } finally { println("finally"); }
+} catch (e) {
+    println(e.message);
+    try { throw "again"; } catch (again) { println(again.message); }
+}
+match ("b") { "a" => { println("a"); } "b", "c" => { println("b"); } _ => { println("_"); } }
+throw "last";`))
+
+	assert.Equal(t, "finally\ninner\nagain\nb\n", output.String())
+	assert.Equal(t, "8:1: last", err.Error())
+}
+
+func compile(t *testing.T, code string) *runtime.Bytecode {
+	return runtime.Compile(parseCode(t, code))
+}
+
+func parseCode(t *testing.T, code string) parse.RootNode {
+	ast, err := parse.NewParser(lex.NewJslLexer(strings.NewReader(code))).Parse()
+
+	if err != nil {
+		t.Fatalf("Unexpected Parse() error: %v", err)
+	}
+
+	return ast
+}
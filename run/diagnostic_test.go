@@ -0,0 +1,40 @@
+package run_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeDiagnostic(t *testing.T) {
+	err := execute(`let a number = 1;
+println(missing);`)
+
+	var diagnostic lex.Diagnostic
+
+	if !errors.As(err, &diagnostic) {
+		t.Fatalf("Expected diagnostic, but got: %v", err)
+	}
+
+	assert.Equal(t, "unknown-identifier", diagnostic.Code)
+	assert.Equal(t, "Unknown identifier: missing", diagnostic.Message)
+	assert.Equal(t, lex.Span{Start: lex.Position{Line: 2, Column: 9}, End: lex.Position{Line: 2, Column: 16}}, diagnostic.Span)
+	assert.True(t, errors.As(err, &runtime.UnknownIdentifier{}))
+}
+
+func TestRuntimeDiagnosticCodes(t *testing.T) {
+	cases := map[string]string{
+		`let a number = 1; let a number = 2;`: "redeclared-symbol",
+		`println("a" + 1);`:                   "unknown-operator",
+		`if (1) { println("a"); }`:            "invalid-condition",
+		`throw "oops";`:                       "uncaught-error",
+		`import "missing.jsl" as missing;`:    "import-not-found",
+	}
+
+	for code, expected := range cases {
+		assert.Equal(t, expected, lex.Diagnose(execute(code)).Code, code)
+	}
+}
@@ -0,0 +1,86 @@
+package run
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/parse"
+	"github.com/ehimen/jaslang/runtime"
+)
+
+// An expression, such as `order.total * 2`, parsed and compiled
+// once so that it can be evaluated against many environments.
+// It may be evaluated from many goroutines at once.
+type Expression struct {
+	compiled *runtime.Expression
+}
+
+// Parses and compiles expr, a single expression without a
+// terminating semicolon. Any error is lex.Diagnostics.
+func CompileExpression(expr string) (*Expression, error) {
+	node, err := parse.ParseExpression(lex.NewJslLexer(strings.NewReader(expr)))
+
+	if err != nil {
+		return nil, diagnostics(err)
+	}
+
+	compiled, err := runtime.CompileExpression(node, runtime.NewBuiltinTable())
+
+	if err != nil {
+		return nil, diagnostics(err)
+	}
+
+	return &Expression{compiled: compiled}, nil
+}
+
+// Evaluates expr with the variables in env, as an Expression.
+// Expressions evaluated more than once should be compiled once
+// with CompileExpression instead.
+func Eval(expr string, env map[string]interface{}) (interface{}, error) {
+	expression, err := CompileExpression(expr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return expression.Eval(env)
+}
+
+// Evaluates the expression with the variables in env, giving its
+// value. Variables are converted by runtime.Marshal and the value
+// is converted back as by runtime.Unmarshal into an interface{},
+// so numbers are float64s, records map[string]interface{}s and
+// so on. Any error is lex.Diagnostics.
+func (e *Expression) Eval(env map[string]interface{}) (interface{}, error) {
+	return e.EvalContext(context.Background(), env, runtime.Limits{})
+}
+
+// As Eval, but stops once ctx is done or any of limits is exceeded.
+func (e *Expression) EvalContext(ctx context.Context, env map[string]interface{}, limits runtime.Limits) (interface{}, error) {
+	variables := make(map[string]runtime.Value, len(env))
+
+	for identifier, v := range env {
+		value, err := runtime.Marshal(v)
+
+		if err != nil {
+			return nil, diagnostics(err)
+		}
+
+		variables[identifier] = value
+	}
+
+	value, err := e.compiled.Evaluate(ctx, variables, limits)
+
+	if err != nil {
+		return nil, diagnostics(err)
+	}
+
+	var result interface{}
+
+	if err := runtime.Unmarshal(value, &result); err != nil {
+		return nil, diagnostics(err)
+	}
+
+	return result, nil
+}
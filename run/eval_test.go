@@ -0,0 +1,119 @@
+package run_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/run"
+	"github.com/ehimen/jaslang/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEval(t *testing.T) {
+	for expr, expected := range map[string]interface{}{
+		`1 + 2 * 3`:                  float64(7),
+		`(price + 1) * quantity`:     float64(6),
+		`"Dear " + name`:             "Dear Ann",
+		`price < 5 && name == "Ann"`: true,
+		`order.items.length`:         float64(2),
+		`order`:                      map[string]interface{}{"total": 12.5, "items": []interface{}{"tea", "cake"}},
+	} {
+		result, err := run.Eval(expr, map[string]interface{}{
+			"price":    2,
+			"quantity": uint8(2),
+			"name":     "Ann",
+			"order": struct {
+				Total float64  `jsl:"total"`
+				Items []string `jsl:"items"`
+			}{12.5, []string{"tea", "cake"}},
+		})
+
+		assert.Nil(t, err, expr)
+		assert.Equal(t, expected, result, expr)
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for expr, expected := range map[string]string{
+		`price + 1;`:   `1:10: Unexpected token ";", expected ")", ",", end of input or operator`,
+		`price = 1`:    `1:7: Unexpected token "=", expected "(", ")", ",", end of input or operator`,
+		`missing + 1`:  `1:1: Unknown identifier: missing`,
+		`price + "a"`:  `1:7: Unknown operator + with operands (number, string)`,
+		`price.length`: `1:7: Unknown member length of number`,
+	} {
+		_, err := run.Eval(expr, map[string]interface{}{"price": 1})
+
+		if assert.Error(t, err, expr) {
+			assert.IsType(t, lex.Diagnostics{}, err, expr)
+			assert.Equal(t, expected, err.Error(), expr)
+		}
+	}
+
+	_, err := run.Eval(`a`, map[string]interface{}{"a": make(chan int)})
+
+	assert.Equal(t, "Cannot marshal chan int", err.Error())
+}
+
+func TestCompiledExpression(t *testing.T) {
+	expression, err := run.CompileExpression(`total * 2 + 1`)
+
+	assert.Nil(t, err)
+
+	wait := sync.WaitGroup{}
+	results := make([]interface{}, 100)
+
+	for i := range results {
+		wait.Add(1)
+
+		go func(i int) {
+			defer wait.Done()
+
+			result, err := expression.Eval(map[string]interface{}{"total": i})
+
+			assert.Nil(t, err)
+			results[i] = result
+		}(i)
+	}
+
+	wait.Wait()
+
+	for i, result := range results {
+		assert.Equal(t, float64(i*2+1), result)
+	}
+}
+
+func TestCompiledExpressionLimited(t *testing.T) {
+	expression, err := run.CompileExpression(`a + b + c`)
+
+	assert.Nil(t, err)
+
+	env := map[string]interface{}{"a": 1, "b": 2, "c": 3}
+
+	_, err = expression.EvalContext(context.Background(), env, runtime.Limits{Nodes: 3})
+
+	if assert.Error(t, err) {
+		assert.Equal(t, "limit-exceeded", err.(lex.Diagnostics)[0].Code)
+	}
+
+	result, err := expression.EvalContext(context.Background(), env, runtime.Limits{Nodes: 5})
+
+	assert.Nil(t, err)
+	assert.Equal(t, float64(6), result)
+}
+
+func BenchmarkCompiledExpression(b *testing.B) {
+	expression, _ := run.CompileExpression(`price * quantity < limit && name == "Ann"`)
+
+	env := map[string]interface{}{"price": 2.5, "quantity": 4, "limit": 100, "name": "Ann"}
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if result, err := expression.Eval(env); err != nil || result != true {
+			panic(fmt.Sprintf("%v %v", result, err))
+		}
+	}
+}
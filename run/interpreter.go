@@ -0,0 +1,157 @@
+package run
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/parse"
+	"github.com/ehimen/jaslang/runtime"
+)
+
+// Configures an Interpreter. The zero value interprets
+// code with no input, discarding its output.
+type Options struct {
+	// Whether syntax errors describe how the parser got to them.
+	Debug bool
+	// Compiles code to bytecode and runs it on a virtual machine,
+	// rather than walking its tree.
+	Compiled bool
+	Limits   runtime.Limits
+	Input    io.Reader
+	Output   io.Writer
+	// Where warnings from imported modules are written.
+	Error io.Writer
+	// Directories in which imports are looked for.
+	SearchPath []string
+	// Functions available to code, by name, as well as
+	// the builtins.
+	Natives map[string]runtime.Invokable
+	// Types available to code, by name, as well as the builtins.
+	Types map[string]runtime.Type
+	// Values declared before code runs, by identifier, such
+	// as Go values converted by runtime.Marshal.
+	Globals map[string]runtime.Value
+}
+
+// Interprets code for a host program. An Interpreter doesn't
+// change once created and each run of code is independent, so
+// one may run code from many goroutines at once, as may many
+// Interpreters. Natives are shared by every run, though, as
+// are any I/O, so they must be safe to share.
+type Interpreter struct {
+	options Options
+}
+
+// What came of running code.
+type Result struct {
+	// The values of everything declared by the code, by identifier.
+	Globals map[string]runtime.Value
+	// Problems found in the code that didn't stop it running.
+	Warnings lex.Diagnostics
+}
+
+func NewInterpreter(options Options) *Interpreter {
+	if options.Input == nil {
+		options.Input = strings.NewReader("")
+	}
+
+	if options.Output == nil {
+		options.Output = ioutil.Discard
+	}
+
+	if options.Error == nil {
+		options.Error = ioutil.Discard
+	}
+
+	return &Interpreter{options: options}
+}
+
+// Runs code, stopping once ctx is done or any limit is exceeded.
+// Any error is lex.Diagnostics, of every syntax error or of the
+// one error that stopped evaluation. The Result has the values
+// of what was declared even if evaluation failed part way.
+func (i *Interpreter) Run(ctx context.Context, code io.RuneReader) (Result, error) {
+	program, warnings, err := i.compile(code)
+
+	if err != nil {
+		return Result{Globals: map[string]runtime.Value{}, Warnings: warnings}, err
+	}
+
+	return program.Run(ctx, Environment{})
+}
+
+// Parses and resolves code into a Program that can be run many
+// times. Any error is lex.Diagnostics, of every syntax error or
+// of every unknown identifier.
+func (i *Interpreter) Compile(code io.RuneReader) (*Program, error) {
+	program, _, err := i.compile(code)
+
+	return program, err
+}
+
+// As Compile, also giving the warnings found in code
+// even if it couldn't be compiled.
+func (i *Interpreter) compile(code io.RuneReader) (*Program, lex.Diagnostics, error) {
+	warnings := lex.Diagnostics{}
+	parser := parse.NewRecoveringParser(lex.NewJslLexer(code))
+
+	if i.options.Debug {
+		parser = parse.Debugging(parser)
+	}
+
+	ast, err := parser.Parse()
+
+	for _, warning := range parser.Warnings() {
+		warnings = append(warnings, warning.Diagnostic())
+	}
+
+	if err != nil {
+		return nil, warnings, diagnostics(err)
+	}
+
+	table, err := i.table()
+
+	if err != nil {
+		return nil, warnings, diagnostics(err)
+	}
+
+	program, err := runtime.NewProgram(ast, table, i.options.Compiled)
+
+	if err != nil {
+		return nil, warnings, diagnostics(err)
+	}
+
+	return &Program{program: program, options: i.options, warnings: warnings}, warnings, nil
+}
+
+// Creates a table with the builtins, natives, types and globals.
+func (i *Interpreter) table() (*runtime.SymbolTable, error) {
+	table := runtime.NewBuiltinTable()
+
+	for name, native := range i.options.Natives {
+		table.AddFunction(name, native)
+	}
+
+	for name, t := range i.options.Types {
+		table.AddType(name, t)
+	}
+
+	for identifier, value := range i.options.Globals {
+		if err := table.DefineValue(identifier, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return table, nil
+}
+
+func diagnostics(err error) lex.Diagnostics {
+	if errs, isDiagnostics := err.(lex.Diagnostics); isDiagnostics {
+		return errs
+	}
+
+	return lex.Diagnostics{lex.Diagnose(err)}
+}
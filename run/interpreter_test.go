@@ -0,0 +1,130 @@
+package run_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/run"
+	"github.com/ehimen/jaslang/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+// Records the strings it's called with.
+type recorder struct {
+	calls []string
+}
+
+func (r *recorder) String() string {
+	return "record() <native>"
+}
+
+func (r *recorder) Type() runtime.Type {
+	return runtime.TypeInvokable
+}
+
+func (r *recorder) Invoke(context *runtime.Context, args []runtime.Value) (error, runtime.Value) {
+	for _, arg := range args {
+		r.calls = append(r.calls, arg.String())
+	}
+
+	return nil, runtime.Void{}
+}
+
+func TestInterpreter(t *testing.T) {
+	output := bytes.NewBufferString("")
+	record := &recorder{}
+
+	interpreter := run.NewInterpreter(run.Options{
+		Output:  output,
+		Natives: map[string]runtime.Invokable{"record": record},
+		Types:   map[string]runtime.Type{"text": runtime.TypeString},
+	})
+
+	result, err := interpreter.Run(context.Background(), strings.NewReader(`let total number = 1 + 2;
+let greeting text = "hello";
+record(greeting);
+println(total);`))
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]runtime.Value{
+		"total":    runtime.Number{Value: 3},
+		"greeting": runtime.String{Value: "hello"},
+	}, result.Globals)
+	assert.Equal(t, []string{"hello"}, record.calls)
+	assert.Equal(t, "3.000\n", output.String())
+}
+
+func TestInterpreterErrors(t *testing.T) {
+	interpreter := run.NewInterpreter(run.Options{})
+
+	result, err := interpreter.Run(context.Background(), strings.NewReader(`let a number = 1;
+println(a + "b");
+let b number = 2;`))
+
+	if diagnostics, isDiagnostics := err.(lex.Diagnostics); assert.True(t, isDiagnostics) && assert.Len(t, diagnostics, 1) {
+		assert.Equal(t, "unknown-operator", diagnostics[0].Code)
+		assert.Equal(t, lex.Position{Line: 2, Column: 11}, diagnostics[0].Start)
+	}
+
+	assert.Equal(t, map[string]runtime.Value{"a": runtime.Number{Value: 1}}, result.Globals)
+
+	_, err = interpreter.Run(context.Background(), strings.NewReader(`let a number = 1 1;
+let b number = 2 2;`))
+
+	assert.Len(t, err, 2)
+}
+
+func TestInterpreterDebug(t *testing.T) {
+	code := `let a number = 1 1;`
+
+	_, err := run.NewInterpreter(run.Options{}).Run(context.Background(), strings.NewReader(code))
+
+	assert.NotContains(t, err.Error(), "Debug:")
+
+	_, err = run.NewInterpreter(run.Options{Debug: true}).Run(context.Background(), strings.NewReader(code))
+
+	assert.Contains(t, err.Error(), "Debug:")
+}
+
+func TestInterpreterLimits(t *testing.T) {
+	for _, compiled := range []bool{false, true} {
+		interpreter := run.NewInterpreter(run.Options{Compiled: compiled, Limits: runtime.Limits{Nodes: 10}})
+
+		_, err := interpreter.Run(context.Background(), strings.NewReader(strings.Repeat(`println("again");`, 20)))
+
+		assert.Len(t, err, 1)
+		assert.Equal(t, "limit-exceeded", err.(lex.Diagnostics)[0].Code)
+	}
+}
+
+func TestInterpretersRunConcurrently(t *testing.T) {
+	interpreter := run.NewInterpreter(run.Options{})
+	wait := sync.WaitGroup{}
+	results := make([]runtime.Value, 20)
+
+	for i := range results {
+		wait.Add(1)
+
+		go func(i int) {
+			defer wait.Done()
+
+			for _, interpreter := range []*run.Interpreter{interpreter, run.NewInterpreter(run.Options{Compiled: true})} {
+				result, err := interpreter.Run(context.Background(), strings.NewReader(fmt.Sprintf(`let n number = %d * 2;`, i)))
+
+				assert.Nil(t, err)
+				results[i] = result.Globals["n"]
+			}
+		}(i)
+	}
+
+	wait.Wait()
+
+	for i, result := range results {
+		assert.Equal(t, runtime.Number{Value: float64(i * 2)}, result)
+	}
+}
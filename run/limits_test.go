@@ -0,0 +1,136 @@
+package run_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ehimen/jaslang/run"
+	"github.com/ehimen/jaslang/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+type limitedEngine func(t *testing.T, ctx context.Context, code string, limits runtime.Limits) (string, error)
+
+var limitedEngines = map[string]limitedEngine{
+	"evaluated": func(t *testing.T, ctx context.Context, code string, limits runtime.Limits) (string, error) {
+		output := bytes.NewBufferString("")
+		err := run.ExecuteContext(ctx, strings.NewReader(code), limits, strings.NewReader(""), output, &bytes.Buffer{})
+
+		return output.String(), err
+	},
+	"compiled": func(t *testing.T, ctx context.Context, code string, limits runtime.Limits) (string, error) {
+		output := bytes.NewBufferString("")
+		err := runtime.NewMachine(strings.NewReader(""), output, &bytes.Buffer{}).EvaluateContext(ctx, parseCode(t, code), limits)
+
+		return output.String(), err
+	},
+}
+
+func TestNodeLimit(t *testing.T) {
+	code := strings.Repeat(`println("again");`+"\n", 100)
+
+	for name, engine := range limitedEngines {
+		output, err := engine(t, context.Background(), code, runtime.Limits{Nodes: 20})
+
+		assertLimitExceeded(t, name, err, runtime.LimitNodes)
+		assert.True(t, strings.HasPrefix(output, "again\n"), name)
+		assert.True(t, strings.Count(output, "again") < 20, name)
+	}
+}
+
+func TestLimitsCantBeCaught(t *testing.T) {
+	code := `try {
+    println("one");
+    println("two");
+    println("three");
+} catch (e) {
+    println("caught");
+} finally {
+    println("finally");
+}`
+
+	for name, engine := range limitedEngines {
+		output, err := engine(t, context.Background(), code, runtime.Limits{Nodes: 5})
+
+		assertLimitExceeded(t, name, err, runtime.LimitNodes)
+		assert.NotContains(t, output, "caught", name)
+		assert.NotContains(t, output, "finally", name)
+	}
+}
+
+func TestCollectionSizeLimit(t *testing.T) {
+	code := `let s string = "abc" + "def";
+println(s);
+s = s + s;`
+
+	for name, engine := range limitedEngines {
+		output, err := engine(t, context.Background(), code, runtime.Limits{CollectionSize: 6})
+
+		assertLimitExceeded(t, name, err, runtime.LimitCollectionSize)
+		assert.Equal(t, "abcdef\n", output, name)
+		assert.Equal(t, "3:7: Collection size of 6 exceeded", err.Error(), name)
+	}
+}
+
+func TestImportDepthLimit(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"one.jsl":   `import "two.jsl" as two;`,
+		"two.jsl":   `import "three.jsl" as three;`,
+		"three.jsl": `println("too deep");`,
+	})
+
+	code := fmt.Sprintf(`import "%s" as one;`, filepath.Join(dir, "one.jsl"))
+
+	for name, engine := range limitedEngines {
+		output, err := engine(t, context.Background(), code, runtime.Limits{ImportDepth: 2})
+
+		assertLimitExceeded(t, name, err, runtime.LimitImportDepth)
+		assert.Equal(t, "", output, name)
+	}
+}
+
+func TestImportDepthDefaultLimit(t *testing.T) {
+	files := map[string]string{}
+
+	for i := 0; i < runtime.DefaultImportDepth; i++ {
+		files[fmt.Sprintf("%d.jsl", i)] = fmt.Sprintf(`import "%d.jsl" as next;`, i+1)
+	}
+
+	files[fmt.Sprintf("%d.jsl", runtime.DefaultImportDepth)] = `println("too deep");`
+
+	dir := writeModules(t, files)
+
+	code := fmt.Sprintf(`import "%s" as first;`, filepath.Join(dir, "0.jsl"))
+
+	for name, engine := range limitedEngines {
+		output, err := engine(t, context.Background(), code, runtime.Limits{})
+
+		assertLimitExceeded(t, name, err, runtime.LimitImportDepth)
+		assert.Equal(t, "", output, name)
+	}
+}
+
+func TestCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for name, engine := range limitedEngines {
+		output, err := engine(t, ctx, `println("never");`, runtime.Limits{})
+
+		assert.True(t, errors.Is(err, context.Canceled), name)
+		assert.Equal(t, "", output, name)
+	}
+}
+
+func assertLimitExceeded(t *testing.T, engine string, err error, limit runtime.Limit) {
+	var exceeded runtime.LimitExceeded
+
+	if assert.True(t, errors.As(err, &exceeded), "%s: expected limit exceeded, got %v", engine, err) {
+		assert.Equal(t, limit, exceeded.Limit, engine)
+	}
+}
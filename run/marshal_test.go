@@ -0,0 +1,227 @@
+package run_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/parse"
+	"github.com/ehimen/jaslang/run"
+	"github.com/ehimen/jaslang/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+type customer struct {
+	Name    string `jsl:"name"`
+	Loyal   bool   `jsl:"loyal"`
+	Account *int   `jsl:"account"`
+	secret  string
+}
+
+type order struct {
+	Total    float64           `jsl:"total"`
+	Customer customer          `jsl:"customer"`
+	Items    []string          `jsl:"items"`
+	Tags     map[string]string `jsl:"tags"`
+	Internal string            `jsl:"-"`
+	Quantity uint16
+}
+
+func TestMarshal(t *testing.T) {
+	value, err := runtime.Marshal(order{
+		Total:    12.5,
+		Customer: customer{Name: "Ann", Loyal: true, secret: "x"},
+		Items:    []string{"tea", "cake"},
+		Tags:     map[string]string{"via": "web"},
+		Internal: "hidden",
+		Quantity: 2,
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, runtime.Record{Fields: map[string]runtime.Value{
+		"total": runtime.Number{Value: 12.5},
+		"customer": runtime.Record{Fields: map[string]runtime.Value{
+			"name":    runtime.String{Value: "Ann"},
+			"loyal":   runtime.Boolean{Value: true},
+			"account": runtime.Void{},
+		}},
+		"items":    runtime.List{Values: []runtime.Value{runtime.String{Value: "tea"}, runtime.String{Value: "cake"}}},
+		"tags":     runtime.Record{Fields: map[string]runtime.Value{"via": runtime.String{Value: "web"}}},
+		"Quantity": runtime.Number{Value: 2},
+	}}, value)
+
+	assert.Equal(t, "{Quantity: 2.000, customer: {account: <void>, loyal: true, name: Ann}, items: [tea, cake], tags: {via: web}, total: 12.500}", value.String())
+
+	var decoded order
+
+	assert.Nil(t, runtime.Unmarshal(value, &decoded))
+	assert.Equal(t, order{
+		Total:    12.5,
+		Customer: customer{Name: "Ann", Loyal: true},
+		Items:    []string{"tea", "cake"},
+		Tags:     map[string]string{"via": "web"},
+		Quantity: 2,
+	}, decoded)
+
+	var generic interface{}
+
+	assert.Nil(t, runtime.Unmarshal(value, &generic))
+	assert.Equal(t, map[string]interface{}{
+		"total": 12.5,
+		"customer": map[string]interface{}{
+			"name":    "Ann",
+			"loyal":   true,
+			"account": nil,
+		},
+		"items":    []interface{}{"tea", "cake"},
+		"tags":     map[string]interface{}{"via": "web"},
+		"Quantity": float64(2),
+	}, generic)
+}
+
+func TestMarshalValues(t *testing.T) {
+	value, err := runtime.Marshal([]interface{}{runtime.Number{Value: 1}, nil, &[2]int{3, 4}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, runtime.List{Values: []runtime.Value{
+		runtime.Number{Value: 1},
+		runtime.Void{},
+		runtime.List{Values: []runtime.Value{runtime.Number{Value: 3}, runtime.Number{Value: 4}}},
+	}}, value)
+
+	var values []runtime.Value
+
+	assert.Nil(t, runtime.Unmarshal(value, &values))
+	assert.Equal(t, value.(runtime.List).Values, values)
+}
+
+func TestMarshalErrors(t *testing.T) {
+	for expected, v := range map[string]interface{}{
+		"Cannot marshal chan int":       make(chan int),
+		"Cannot marshal map[int]string": map[int]string{1: "a"},
+		"Cannot marshal func()":         []func(){func() {}},
+		"Cannot marshal complex128":     struct{ C complex128 }{},
+	} {
+		_, err := runtime.Marshal(v)
+
+		if assert.Error(t, err) {
+			assert.Equal(t, expected, err.Error())
+			assert.Equal(t, "cannot-marshal", lex.Diagnose(err).Code)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var n int8
+	var s string
+	var pair [1]string
+
+	for expected, unmarshal := range map[string]func() error{
+		"Cannot unmarshal string into int8":                                      func() error { return runtime.Unmarshal(runtime.String{Value: "a"}, &n) },
+		"Cannot unmarshal 1.500 into int8, as it isn't whole or is out of range": func() error { return runtime.Unmarshal(runtime.Number{Value: 1.5}, &n) },
+		"Cannot unmarshal 200.000 into int8, as it isn't whole or is out of range": func() error {
+			return runtime.Unmarshal(runtime.Number{Value: 200}, &n)
+		},
+		"Cannot unmarshal record into string": func() error { return runtime.Unmarshal(runtime.Record{}, &s) },
+		"Cannot unmarshal a list of 2 values into [1]string": func() error {
+			return runtime.Unmarshal(runtime.List{Values: []runtime.Value{runtime.String{}, runtime.String{}}}, &pair)
+		},
+		"Cannot unmarshal into string, which isn't a non-nil pointer": func() error { return runtime.Unmarshal(runtime.String{}, s) },
+	} {
+		err := unmarshal()
+
+		if assert.Error(t, err) {
+			assert.Equal(t, expected, err.Error())
+			assert.Equal(t, "cannot-unmarshal", lex.Diagnose(err).Code)
+		}
+	}
+}
+
+func TestMarshalledGlobals(t *testing.T) {
+	value, err := runtime.Marshal(order{Total: 10, Customer: customer{Name: "Ann"}, Items: []string{"tea"}})
+
+	assert.Nil(t, err)
+
+	for _, compiled := range []bool{false, true} {
+		output := bytes.NewBufferString("")
+		interpreter := run.NewInterpreter(run.Options{
+			Compiled: compiled,
+			Output:   output,
+			Globals:  map[string]runtime.Value{"order": value},
+		})
+
+		result, err := interpreter.Run(context.Background(), strings.NewReader(`let discounted number = order.total * 0.9;
+let items number = order.items.length;
+println(order.customer.name);`))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Ann\n", output.String())
+
+		var discounted float64
+		var items int
+
+		assert.Nil(t, runtime.Unmarshal(result.Globals["discounted"], &discounted))
+		assert.Nil(t, runtime.Unmarshal(result.Globals["items"], &items))
+		assert.Equal(t, 9.0, discounted)
+		assert.Equal(t, 1, items)
+	}
+}
+
+func TestDefineMarshalled(t *testing.T) {
+	table := runtime.NewBuiltinTable()
+	value, _ := runtime.Marshal(customer{Name: "Ann"})
+
+	assert.Nil(t, table.Define("customer", runtime.TypeRecord))
+	assert.Nil(t, table.Set("customer", value))
+	assert.Nil(t, table.Define("greeting", runtime.TypeString))
+
+	output := bytes.NewBufferString("")
+	evaluator := runtime.NewContextEvaluator(&runtime.Context{Table: table, Output: output, Modules: runtime.NewModules(nil)})
+	ast, err := parse.NewParser(lex.NewJslLexer(strings.NewReader(`greeting = "Hello " + customer.name;`))).Parse()
+
+	assert.Nil(t, err)
+	assert.Nil(t, evaluator.Evaluate(ast))
+
+	var greeting string
+	greetingValue, _ := table.Get("greeting")
+
+	assert.Nil(t, runtime.Unmarshal(greetingValue, &greeting))
+	assert.Equal(t, "Hello Ann", greeting)
+}
+
+type node struct {
+	Name string `jsl:"name"`
+	Next *node  `jsl:"next"`
+}
+
+func TestMarshalCycles(t *testing.T) {
+	looped := &node{Name: "a"}
+	looped.Next = &node{Name: "b", Next: looped}
+
+	nested := map[string]interface{}{}
+	nested["self"] = nested
+
+	list := []interface{}{nil}
+	list[0] = list
+
+	for _, v := range []interface{}{looped, nested, list} {
+		_, err := runtime.Marshal(v)
+
+		if assert.Error(t, err) {
+			assert.Equal(t, "cannot-marshal", lex.Diagnose(err).Code)
+		}
+	}
+
+	_, err := runtime.Marshal(looped)
+
+	assert.Equal(t, "Cannot marshal *run_test.node, as it contains itself", err.Error())
+
+	// Values seen twice without containing themselves are fine.
+	shared := &node{Name: "shared"}
+	value, err := runtime.Marshal([]*node{shared, shared})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "[{name: shared, next: <void>}, {name: shared, next: <void>}]", value.String())
+}
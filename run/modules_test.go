@@ -0,0 +1,209 @@
+package run_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ehimen/jaslang/run"
+	"github.com/ehimen/jaslang/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImport(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.jsl": `import "lib/util.jsl" as util;
+println(util.greeting, util.answer);`,
+		"lib/util.jsl": `import "common.jsl" as common;
+export let greeting string = "hello " + common.name;
+export let answer number = 42;`,
+		"lib/common.jsl": `export let name string = "world";`,
+	})
+
+	output, errors, failed := interpretFile(filepath.Join(dir, "main.jsl"), nil)
+
+	assert.False(t, failed, errors)
+	assert.Equal(t, "hello world\n42.000\n", output)
+}
+
+func TestImportFromSearchPath(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"scripts/main.jsl": `import "common.jsl" as common;
+println(common.name);`,
+		"shared/common.jsl": `export let name string = "shared";`,
+	})
+
+	output, errors, failed := interpretFile(
+		filepath.Join(dir, "scripts", "main.jsl"),
+		[]string{filepath.Join(dir, "missing"), filepath.Join(dir, "shared")},
+	)
+
+	assert.False(t, failed, errors)
+	assert.Equal(t, "shared\n", output)
+}
+
+func TestImportEvaluatesOnce(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.jsl": `import "one.jsl" as one;
+import "two.jsl" as two;`,
+		"one.jsl":    `import "common.jsl" as common;`,
+		"two.jsl":    `import "common.jsl" as common;`,
+		"common.jsl": `println("loaded");`,
+	})
+
+	output, errors, failed := interpretFile(filepath.Join(dir, "main.jsl"), nil)
+
+	assert.False(t, failed, errors)
+	assert.Equal(t, "loaded\n", output)
+}
+
+func TestImportOnlyExports(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.jsl": `import "lib.jsl" as lib;
+println(lib.hidden);`,
+		"lib.jsl": `let hidden number = 1;`,
+	})
+
+	_, errors, failed := interpretFile(filepath.Join(dir, "main.jsl"), nil)
+
+	assert.True(t, failed)
+	assert.Equal(t, filepath.Join(dir, "main.jsl")+":2:13: Unknown member hidden of module", errors)
+}
+
+func TestImportCycle(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"a.jsl": `import "b.jsl" as b;`,
+		"b.jsl": `import "a.jsl" as a;`,
+	})
+
+	_, errors, failed := interpretFile(filepath.Join(dir, "a.jsl"), nil)
+
+	a, b := filepath.Join(dir, "a.jsl"), filepath.Join(dir, "b.jsl")
+
+	assert.True(t, failed)
+	assert.Equal(t, b+":1:1: Import cycle: "+a+" -> "+b+" -> "+a, errors)
+}
+
+func TestImportDepthLimited(t *testing.T) {
+	files := map[string]string{}
+
+	for i := 0; i <= runtime.DefaultImportDepth; i++ {
+		files[fmt.Sprintf("%d.jsl", i)] = fmt.Sprintf(`import "%d.jsl" as next;`, i+1)
+	}
+
+	files[fmt.Sprintf("%d.jsl", runtime.DefaultImportDepth+1)] = `println("too deep");`
+
+	dir := writeModules(t, files)
+
+	output, errors, failed := interpretFile(filepath.Join(dir, "0.jsl"), nil)
+
+	assert.True(t, failed)
+	assert.Equal(t, "", output)
+	assert.Equal(t, fmt.Sprintf("%s:1:1: Import depth of %d exceeded", filepath.Join(dir, "99.jsl"), runtime.DefaultImportDepth), errors)
+}
+
+func TestImportErrorNamesFile(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.jsl": `import "lib.jsl" as lib;`,
+		"lib.jsl":  `println("hello" + 2);`,
+	})
+
+	_, errors, failed := interpretFile(filepath.Join(dir, "main.jsl"), nil)
+
+	assert.True(t, failed)
+	assert.Equal(t, filepath.Join(dir, "lib.jsl")+":1:17: Unknown operator + with operands (string, number)", errors)
+}
+
+func TestSyntaxErrorNamesFile(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.jsl": `import "lib.jsl" as lib;`,
+		"lib.jsl": `let a number = 1;
+let = 2;`,
+	})
+
+	_, errors, failed := interpretFile(filepath.Join(dir, "main.jsl"), nil)
+
+	assert.True(t, failed)
+	assert.Equal(t, filepath.Join(dir, "lib.jsl")+":2:5: Unexpected token \"=\", expected identifier", errors)
+}
+
+func TestImportReportsAllSyntaxErrors(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.jsl": `import "lib.jsl" as lib;`,
+		"lib.jsl": `let = 1;
+let = 2;`,
+	})
+
+	_, errors, failed := interpretFile(filepath.Join(dir, "main.jsl"), nil)
+
+	lib := filepath.Join(dir, "lib.jsl")
+
+	assert.True(t, failed)
+	assert.Equal(t, lib+":1:5: Unexpected token \"=\", expected identifier\n"+lib+":2:5: Unexpected token \"=\", expected identifier", errors)
+}
+
+func TestCaughtErrorNamesFile(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.jsl": `try { throw "oops"; } catch (e) { println(e.file); }`,
+	})
+
+	output, _, failed := interpretFile(filepath.Join(dir, "main.jsl"), nil)
+
+	assert.False(t, failed)
+	assert.Equal(t, filepath.Join(dir, "main.jsl")+"\n", output)
+}
+
+func writeModules(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "jaslang")
+
+	if err != nil {
+		t.Fatalf("Cannot create module directory: %v", err)
+	}
+
+	dir, _ = filepath.EvalSymlinks(dir)
+
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	for name, code := range files {
+		path := filepath.Join(dir, name)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Cannot create module directory: %v", err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(code), 0644); err != nil {
+			t.Fatalf("Cannot write module: %v", err)
+		}
+	}
+
+	return dir
+}
+
+func interpretFile(file string, searchPath []string) (string, string, bool) {
+	output := bytes.NewBufferString("")
+	errors := bytes.NewBufferString("")
+
+	failed := run.InterpretFile(file, searchPath, strings.NewReader(""), output, errors)
+
+	return output.String(), errors.String(), failed
+}
+
+func TestImportCompiled(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.jsl": `import "lib.jsl" as lib;
+println(lib.greeting);
+try { println(lib.missing); } catch (e) { println(e.message); }`,
+		"lib.jsl": `export let greeting string = "hello";
+let hidden number = 1;`,
+	})
+
+	output := bytes.NewBufferString("")
+	err := run.ExecuteFileCompiled(filepath.Join(dir, "main.jsl"), nil, strings.NewReader(""), output, &bytes.Buffer{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "hello\nUnknown member missing of module\n", output.String())
+}
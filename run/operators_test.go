@@ -0,0 +1,44 @@
+package run_test
+
+import (
+	"testing"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperatorOverloadsAreOrdered(t *testing.T) {
+	table := runtime.NewBuiltinTable()
+	assert.Nil(t, table.AddOperator("*", runtime.Types{runtime.TypeString, runtime.TypeNumber}, runtime.AddNumbers{}))
+	assert.Nil(t, table.AddOperator("*", runtime.Types{runtime.TypeNumber, runtime.TypeString}, runtime.SubtractNumbers{}))
+
+	stringFirst, err := table.Operator("*", runtime.Types{runtime.TypeString, runtime.TypeNumber})
+
+	assert.Nil(t, err)
+	assert.Equal(t, runtime.AddNumbers{}, stringFirst)
+
+	numberFirst, err := table.Operator("*", runtime.Types{runtime.TypeNumber, runtime.TypeString})
+
+	assert.Nil(t, err)
+	assert.Equal(t, runtime.SubtractNumbers{}, numberFirst)
+
+	_, err = table.Operator("*", runtime.Types{runtime.TypeString, runtime.TypeString})
+
+	assert.Equal(t, "Unknown operator * with operands (string, string)", err.Error())
+}
+
+func TestOperatorCannotBeRedeclared(t *testing.T) {
+	table := runtime.NewBuiltinTable()
+	err := table.AddOperator("+", runtime.Types{runtime.TypeNumber, runtime.TypeNumber}, runtime.SubtractNumbers{})
+
+	if assert.Error(t, err) {
+		assert.Equal(t, "redeclared-operator", lex.Diagnose(err).Code)
+		assert.Equal(t, "Cannot declare operator + with operands (number, number) again", err.Error())
+	}
+
+	operation, err := table.Operator("+", runtime.Types{runtime.TypeNumber, runtime.TypeNumber})
+
+	assert.Nil(t, err)
+	assert.Equal(t, runtime.AddNumbers{}, operation)
+}
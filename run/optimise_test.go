@@ -0,0 +1,50 @@
+package run_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ehimen/jaslang/parse"
+	"github.com/ehimen/jaslang/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptimise(t *testing.T) {
+	ast := runtime.Optimise(parseCode(t, `let a number = 1 + 2 * 3;
+if (1 == 2) { println("never"); }
+println("a" + "b");
+println(a + (2 - 1));
+println(a / 0);`), runtime.NewBuiltinTable())
+
+	optimised, err := json.Marshal(ast)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `[`+
+		`{"Type":"statement","Children":[{"Type":"declaration","ValueType":"number","Identifier":"a","Children":[{"Type":"number","Value":7}]}]},`+
+		`{"Type":"statement","Children":[{"Type":"statement","Children":null}]},`+
+		`{"Type":"statement","Children":[{"Type":"function","Identifier":"println","Children":[{"Type":"string","Value":"ab"}]}]},`+
+		`{"Type":"statement","Children":[{"Type":"function","Identifier":"println","Children":[{"Children":["a",{"Type":"number","Value":1}],"Type":"operator","Operator":"+"}]}]},`+
+		`{"Type":"statement","Children":[{"Type":"function","Identifier":"println","Children":[{"Children":["a",{"Type":"number","Value":0}],"Type":"operator","Operator":"/"}]}]}`+
+		`]`, string(optimised))
+}
+
+func TestOptimisedKeepsPositions(t *testing.T) {
+	code := `println("ok");
+println((1 + 2) + "a");`
+
+	for _, optimise := range []bool{false, true} {
+		var ast parse.Node = parseCode(t, code)
+
+		if optimise {
+			ast = runtime.Optimise(ast, runtime.NewBuiltinTable())
+		}
+
+		output := bytes.NewBufferString("")
+		err := runtime.NewEvaluator(strings.NewReader(""), output, &bytes.Buffer{}).Evaluate(ast)
+
+		assert.Equal(t, "ok\n", output.String())
+		assert.Equal(t, "2:17: Unknown operator + with operands (number, string)", err.Error())
+	}
+}
@@ -0,0 +1,90 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/runtime"
+)
+
+// Code compiled by an Interpreter, to be run many times
+// without being parsed again. As an Interpreter, a Program
+// doesn't change once compiled and may be run from many
+// goroutines at once.
+type Program struct {
+	program  *runtime.Program
+	options  Options
+	warnings lex.Diagnostics
+}
+
+// The I/O and globals of one run of a Program. What isn't
+// given is as given by the Options of the Program's Interpreter.
+type Environment struct {
+	Input  io.Reader
+	Output io.Writer
+	Error  io.Writer
+	// Values of globals, by identifier, in place of those in
+	// the Options. Code is resolved when it's compiled, so only
+	// globals in the Options may be given.
+	Globals map[string]runtime.Value
+}
+
+// Runs the program in env, as Interpreter.Run runs code.
+func (p *Program) Run(ctx context.Context, env Environment) (Result, error) {
+	result := Result{Globals: map[string]runtime.Value{}, Warnings: p.warnings}
+	table := p.program.Table()
+
+	for identifier := range env.Globals {
+		if _, declared := p.options.Globals[identifier]; !declared {
+			return result, diagnostics(lex.CodedError{
+				Code:    "undeclared-global",
+				Message: fmt.Sprintf(`Global "%s" must be declared by the Interpreter's Options`, identifier),
+			})
+		}
+	}
+
+	for identifier, value := range p.options.Globals {
+		if given, isGiven := env.Globals[identifier]; isGiven {
+			value = given
+		}
+
+		if err := table.DefineValue(identifier, value); err != nil {
+			return result, diagnostics(err)
+		}
+	}
+
+	context := &runtime.Context{
+		Table:   table,
+		Input:   p.options.Input,
+		Output:  p.options.Output,
+		Error:   p.options.Error,
+		Modules: runtime.NewModules(p.options.SearchPath),
+	}
+
+	if env.Input != nil {
+		context.Input = env.Input
+	}
+
+	if env.Output != nil {
+		context.Output = env.Output
+	}
+
+	if env.Error != nil {
+		context.Error = env.Error
+	}
+
+	if p.options.Compiled {
+		context.Modules = runtime.NewCompilingModules(p.options.SearchPath)
+	}
+
+	err := p.program.Run(ctx, context, p.options.Limits)
+	result.Globals = table.Values()
+
+	if err != nil {
+		return result, diagnostics(err)
+	}
+
+	return result, nil
+}
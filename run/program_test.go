@@ -0,0 +1,113 @@
+package run_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/run"
+	"github.com/ehimen/jaslang/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgramRunsConcurrently(t *testing.T) {
+	for _, compiled := range []bool{false, true} {
+		interpreter := run.NewInterpreter(run.Options{
+			Compiled: compiled,
+			Globals:  map[string]runtime.Value{"n": runtime.Number{Value: 0}},
+		})
+
+		program, err := interpreter.Compile(strings.NewReader(`let doubled number = n * 2;
+println(doubled);`))
+
+		assert.Nil(t, err)
+
+		wait := sync.WaitGroup{}
+		outputs := make([]*bytes.Buffer, 20)
+		results := make([]run.Result, len(outputs))
+
+		for i := range outputs {
+			wait.Add(1)
+			outputs[i] = bytes.NewBufferString("")
+
+			go func(i int) {
+				defer wait.Done()
+
+				result, err := program.Run(context.Background(), run.Environment{
+					Output:  outputs[i],
+					Globals: map[string]runtime.Value{"n": runtime.Number{Value: float64(i)}},
+				})
+
+				assert.Nil(t, err)
+				results[i] = result
+			}(i)
+		}
+
+		wait.Wait()
+
+		for i, output := range outputs {
+			assert.Equal(t, fmt.Sprintf("%d.000\n", i*2), output.String())
+			assert.Equal(t, map[string]runtime.Value{
+				"n":       runtime.Number{Value: float64(i)},
+				"doubled": runtime.Number{Value: float64(i * 2)},
+			}, results[i].Globals)
+		}
+	}
+}
+
+func TestProgramRunsAreIndependent(t *testing.T) {
+	program, err := run.NewInterpreter(run.Options{}).Compile(strings.NewReader(`let a number = 1;
+a = a + 1;`))
+
+	assert.Nil(t, err)
+
+	for i := 0; i < 2; i++ {
+		result, err := program.Run(context.Background(), run.Environment{})
+
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]runtime.Value{"a": runtime.Number{Value: 2}}, result.Globals)
+	}
+}
+
+func TestProgramDefaultGlobals(t *testing.T) {
+	program, err := run.NewInterpreter(run.Options{
+		Globals: map[string]runtime.Value{"greeting": runtime.String{Value: "hello"}},
+	}).Compile(strings.NewReader(`println(greeting);`))
+
+	assert.Nil(t, err)
+
+	output := bytes.NewBufferString("")
+	_, err = program.Run(context.Background(), run.Environment{Output: output})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "hello\n", output.String())
+
+	_, err = program.Run(context.Background(), run.Environment{
+		Globals: map[string]runtime.Value{"other": runtime.String{Value: "x"}},
+	})
+
+	if assert.Error(t, err) {
+		assert.Equal(t, "undeclared-global", err.(lex.Diagnostics)[0].Code)
+		assert.Equal(t, `Global "other" must be declared by the Interpreter's Options`, err.Error())
+	}
+}
+
+func TestProgramCompileErrors(t *testing.T) {
+	interpreter := run.NewInterpreter(run.Options{})
+
+	_, err := interpreter.Compile(strings.NewReader(`let a number = 1 1;`))
+
+	if assert.Error(t, err) {
+		assert.Equal(t, "unexpected-token", err.(lex.Diagnostics)[0].Code)
+	}
+
+	_, err = interpreter.Compile(strings.NewReader(`println(missing);`))
+
+	if assert.Error(t, err) {
+		assert.Equal(t, "1:9: Unknown identifier: missing", err.Error())
+	}
+}
@@ -0,0 +1,124 @@
+package run
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ehimen/jaslang/lex"
+)
+
+// Renders errors along with the line of source they
+// were raised at, marking the offending span.
+type Renderer struct {
+	// Lines of source to show either side of the error.
+	Context int
+	sources map[string][]string
+}
+
+func NewRenderer(context int) *Renderer {
+	return &Renderer{Context: context, sources: make(map[string][]string)}
+}
+
+// Registers code as the source of file, for sources
+// that can't be read from disk, such as stdin.
+func (r *Renderer) AddSource(file string, code string) {
+	r.sources[file] = strings.Split(strings.TrimSuffix(code, "\n"), "\n")
+}
+
+// Renders err, or each of the errors it wraps. Errors that
+// don't know where they were raised, or whose source can't
+// be found, are rendered as their message alone.
+func (r *Renderer) Render(err error) string {
+	if errs, isMany := err.(interface{ Unwrap() []error }); isMany {
+		rendered := []string{}
+
+		for _, err := range errs.Unwrap() {
+			rendered = append(rendered, r.Render(err))
+		}
+
+		return strings.Join(rendered, "\n")
+	}
+
+	diagnostic := lex.Diagnose(err)
+	position := diagnostic.Start
+	lines, found := r.lines(position.File)
+
+	if !found || position.Line < 1 || position.Line > len(lines) {
+		return err.Error()
+	}
+
+	length := 1
+
+	if diagnostic.Length() > 1 {
+		length = diagnostic.Length()
+	}
+
+	first, last := position.Line-r.Context, position.Line+r.Context
+
+	if first < 1 {
+		first = 1
+	}
+
+	if last > len(lines) {
+		last = len(lines)
+	}
+
+	width := len(fmt.Sprint(last))
+
+	snippet := []string{err.Error()}
+
+	for number := first; number <= last; number++ {
+		snippet = append(snippet, fmt.Sprintf("%*d | %s", width, number, lines[number-1]))
+
+		if number == position.Line {
+			snippet = append(snippet, fmt.Sprintf(
+				"%s | %s",
+				strings.Repeat(" ", width),
+				marker(lines[number-1], position.Column, length),
+			))
+		}
+	}
+
+	return strings.Join(snippet, "\n")
+}
+
+func (r *Renderer) lines(file string) ([]string, bool) {
+	if lines, found := r.sources[file]; found {
+		return lines, true
+	}
+
+	if len(file) == 0 {
+		return nil, false
+	}
+
+	code, err := ioutil.ReadFile(file)
+
+	if err != nil {
+		return nil, false
+	}
+
+	r.AddSource(file, string(code))
+
+	return r.sources[file], true
+}
+
+// Builds the ^~~~ marker under length runes of line from
+// column, keeping tabs so that it lines up with the source.
+func marker(line string, column int, length int) string {
+	indent := []rune{}
+
+	for i, char := range []rune(line) {
+		if i >= column-1 {
+			break
+		}
+
+		if char == '\t' {
+			indent = append(indent, '\t')
+		} else {
+			indent = append(indent, ' ')
+		}
+	}
+
+	return string(indent) + "^" + strings.Repeat("~", length-1)
+}
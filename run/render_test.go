@@ -0,0 +1,77 @@
+package run_test
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ehimen/jaslang/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderSyntaxError(t *testing.T) {
+	code := `let a number = 1;
+println(a,, a);`
+
+	renderer := run.NewRenderer(0)
+	renderer.AddSource("", code)
+
+	assert.Equal(t, `2:11: Unexpected token ",", expected identifier or string
+2 | println(a,, a);
+  |           ^`, renderer.Render(execute(code)))
+}
+
+func TestRenderSpan(t *testing.T) {
+	code := `println(missing);`
+
+	renderer := run.NewRenderer(0)
+	renderer.AddSource("", code)
+
+	assert.Equal(t, `1:9: Unknown identifier: missing
+1 | println(missing);
+  |         ^~~~~~~`, renderer.Render(execute(code)))
+}
+
+func TestRenderContext(t *testing.T) {
+	code := "let a number = 1;\nlet b number = 2;\n\tprintln(a + \"x\");\nlet c number = 3;\nlet d number = 4;"
+
+	renderer := run.NewRenderer(1)
+	renderer.AddSource("", code)
+
+	assert.Equal(
+		t,
+		"3:12: Unknown operator + with operands (number, string)\n"+
+			"2 | let b number = 2;\n"+
+			"3 | \tprintln(a + \"x\");\n"+
+			"  | \t          ^\n"+
+			"4 | let c number = 3;",
+		renderer.Render(execute(code)),
+	)
+}
+
+func TestRenderReadsFile(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.jsl": `import "lib.jsl" as lib;`,
+		"lib.jsl":  `let = 2;`,
+	})
+
+	file := filepath.Join(dir, "lib.jsl")
+	err := run.ExecuteFile(filepath.Join(dir, "main.jsl"), nil, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{})
+
+	assert.Equal(t, file+`:1:5: Unexpected token "=", expected identifier
+1 | let = 2;
+  |     ^`, run.NewRenderer(0).Render(err))
+}
+
+func TestRenderWithoutSource(t *testing.T) {
+	renderer := run.NewRenderer(0)
+
+	assert.Equal(t, `1:9: Unknown identifier: missing`, renderer.Render(execute(`println(missing);`)))
+	assert.Equal(t, "Not located", renderer.Render(errors.New("Not located")))
+}
+
+func execute(code string) error {
+	return run.Execute(strings.NewReader(code), strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{})
+}
@@ -0,0 +1,39 @@
+package run_test
+
+import (
+	"testing"
+
+	"github.com/ehimen/jaslang/parse"
+	"github.com/ehimen/jaslang/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolve(t *testing.T) {
+	ast := parseCode(t, `let a number = 1;
+a = 2;
+println(a);`)
+
+	assert.Nil(t, runtime.Resolve(ast, runtime.NewBuiltinTable()))
+
+	let := ast.Statements[0].Children()[0].(*parse.Let)
+	assignment := ast.Statements[1].Children()[0].(*parse.Assignment)
+	call := ast.Statements[2].Children()[0].(*parse.FunctionCall)
+	use := call.Children()[0].(*parse.Identifier)
+
+	assert.True(t, let.Identifier.Resolved)
+	assert.Equal(t, let.Identifier.Binding, assignment.Identifier.Binding)
+	assert.Equal(t, let.Identifier.Binding, use.Binding)
+	assert.True(t, call.Identifier.Resolved)
+	assert.NotEqual(t, let.Identifier.Slot, call.Identifier.Slot)
+}
+
+func TestResolveReportsEveryUnknownIdentifier(t *testing.T) {
+	err := runtime.Resolve(parseCode(t, `println(a);
+let b number = b;
+c.member = 1;
+println(b.member);`), runtime.NewBuiltinTable())
+
+	assert.Equal(t, `1:9: Unknown identifier: a
+2:16: Unknown identifier: b
+3:1: Unknown identifier: c`, err.Error())
+}
@@ -1,6 +1,7 @@
 package run
 
 import (
+	"context"
 	"io"
 
 	"github.com/ehimen/jaslang/lex"
@@ -8,20 +9,93 @@ import (
 	"github.com/ehimen/jaslang/runtime"
 )
 
+// Interprets code, writing any error to error. Returns
+// whether an error was encountered.
 func Interpret(code io.RuneReader, input io.Reader, output io.Writer, error io.Writer) bool {
-	parser := parse.NewParser(lex.NewJslLexer(code))
+	return report(Execute(code, input, output, error), error)
+}
+
+// Interprets the code in file. Imports are resolved relative
+// to the importing file, then each directory in searchPath.
+func InterpretFile(file string, searchPath []string, input io.Reader, output io.Writer, error io.Writer) bool {
+	return report(ExecuteFile(file, searchPath, input, output, error), error)
+}
+
+// As Interpret, but the code is compiled to bytecode and
+// run by a virtual machine. Compiling costs more than it
+// saves on a single run, so this is slower than Interpret;
+// the machine only pays off when code is compiled once
+// and run many times, as by Interpreter.Compile's Program.
+func InterpretCompiled(code io.RuneReader, input io.Reader, output io.Writer, error io.Writer) bool {
+	return report(ExecuteCompiled(code, input, output, error), error)
+}
 
-	if ast, err := parser.Parse(); err != nil {
-		error.Write([]byte(err.Error()))
+// Interprets code, returning any error encountered.
+// Warnings are written to error.
+func Execute(code io.RuneReader, input io.Reader, output io.Writer, error io.Writer) error {
+	return execute(code, runtime.NewEvaluator(input, output, error).Evaluate, error)
+}
 
-		return true
-	} else {
-		if err := runtime.NewEvaluator(input, output, error).Evaluate(ast); err != nil {
-			error.Write([]byte(err.Error()))
+// As Execute, but the code is compiled and run
+// by a virtual machine.
+func ExecuteCompiled(code io.RuneReader, input io.Reader, output io.Writer, error io.Writer) error {
+	return execute(code, runtime.NewMachine(input, output, error).Evaluate, error)
+}
 
-			return true
-		}
+// As Execute, but stops once ctx is done or any of limits is
+// exceeded. The error is then a lex.Diagnostic wrapping a
+// runtime.Cancelled or runtime.LimitExceeded.
+func ExecuteContext(ctx context.Context, code io.RuneReader, limits runtime.Limits, input io.Reader, output io.Writer, error io.Writer) error {
+	return execute(code, limited(runtime.NewEvaluator(input, output, error), ctx, limits), error)
+}
+
+func limited(evaluator runtime.Evaluator, ctx context.Context, limits runtime.Limits) func(parse.Node) error {
+	return func(ast parse.Node) error {
+		return evaluator.EvaluateContext(ctx, ast, limits)
 	}
+}
+
+// As InterpretFile, but returns any error encountered.
+func ExecuteFile(file string, searchPath []string, input io.Reader, output io.Writer, error io.Writer) error {
+	return executeFile(file, runtime.NewModules(searchPath), input, output, error)
+}
+
+// As ExecuteFile, but modules are compiled and run
+// by a virtual machine.
+func ExecuteFileCompiled(file string, searchPath []string, input io.Reader, output io.Writer, error io.Writer) error {
+	return executeFile(file, runtime.NewCompilingModules(searchPath), input, output, error)
+}
+
+func execute(code io.RuneReader, evaluate func(parse.Node) error, error io.Writer) error {
+	parser := parse.NewRecoveringParser(lex.NewJslLexer(code))
+
+	ast, err := parser.Parse()
+
+	for _, warning := range parser.Warnings() {
+		error.Write([]byte("Warning: " + warning.String() + "\n"))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return evaluate(ast)
+}
+
+func executeFile(file string, modules *runtime.Modules, input io.Reader, output io.Writer, error io.Writer) error {
+	context := &runtime.Context{Input: input, Output: output, Error: error}
+
+	_, err := modules.Load(file, context)
+
+	return err
+}
+
+func report(err error, error io.Writer) bool {
+	if err == nil {
+		return false
+	}
+
+	error.Write([]byte(err.Error()))
 
-	return false
+	return true
 }
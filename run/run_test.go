@@ -42,7 +42,34 @@ func (t *testCase) isValid() bool {
 	return len(t.name) > 0 && t.code != nil && (t.output != nil || t.error != nil)
 }
 
+type interpreter func(code io.RuneReader, input io.Reader, output io.Writer, error io.Writer) bool
+
 func TestJslt(t *testing.T) {
+	testJslt(t, run.Interpret)
+}
+
+func TestJsltCompiled(t *testing.T) {
+	testJslt(t, run.InterpretCompiled)
+}
+
+// Machine-generated code can nest far deeper than the Go stack
+// would allow if each node were evaluated by a recursive call.
+func TestLongExpression(t *testing.T) {
+	code := "println(1" + strings.Repeat(" + 1", 99999) + ");"
+
+	for name, interpret := range map[string]interpreter{"evaluated": run.Interpret, "compiled": run.InterpretCompiled} {
+		output := bytes.NewBufferString("")
+		errors := bytes.NewBufferString("")
+
+		if interpret(strings.NewReader(code), strings.NewReader(""), output, errors) {
+			t.Errorf("%s: unexpected error: %s", name, errors.String())
+		} else if output.String() != "100000.000\n" {
+			t.Errorf("%s: expected 100000.000, got %s", name, output.String())
+		}
+	}
+}
+
+func testJslt(t *testing.T, interpret interpreter) {
 	if tests, loaded := loadTests(t); !loaded {
 		return
 	} else {
@@ -53,7 +80,7 @@ func TestJslt(t *testing.T) {
 					actual := bytes.NewBufferString("")
 					actualError := bytes.NewBufferString("")
 
-					encounteredError := run.Interpret(test.code, test.input, actual, actualError)
+					encounteredError := interpret(test.code, test.input, actual, actualError)
 
 					fail := func(msg string) {
 						t.Errorf("\"%s\" failed!\n%s", test.name, msg)
@@ -0,0 +1,249 @@
+package runtime
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/ehimen/jaslang/lex"
+)
+
+var (
+	contextType = reflect.TypeOf((*Context)(nil))
+	valueType   = reflect.TypeOf((*Value)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// A Go func bound as a native by Bind.
+type boundFunction struct {
+	name string
+	fn   reflect.Value
+	// Whether the func takes the context as its first parameter.
+	context    bool
+	params     []reflect.Type
+	paramTypes []Type
+	// Whether the func returns a value, and whether an error.
+	returns    bool
+	fails      bool
+	returnType Type
+}
+
+// Binds fn, which must be a func, as a native called name. Its
+// parameters may be strings, bools, numbers of any kind or Values,
+// optionally preceded by a *Context. It may return anything that
+// Marshal accepts, an error, both, or nothing. Arguments are
+// checked against the parameters when it's invoked, and converted
+// to them; its result is marshalled and any error it returns is
+// raised.
+func Bind(name string, fn interface{}) (Invokable, error) {
+	f := reflect.ValueOf(fn)
+
+	if f.Kind() != reflect.Func {
+		return nil, invalidBinding(name, fmt.Sprintf("%T is not a func", fn))
+	}
+
+	t := f.Type()
+	bound := &boundFunction{name: name, fn: f, returnType: TypeNone}
+
+	if t.IsVariadic() {
+		return nil, invalidBinding(name, "variadic funcs are not supported")
+	}
+
+	for i := 0; i < t.NumIn(); i++ {
+		param := t.In(i)
+
+		if i == 0 && param == contextType {
+			bound.context = true
+
+			continue
+		}
+
+		paramType, supported := typeOfGo(param)
+
+		if !supported {
+			return nil, invalidBinding(name, fmt.Sprintf("parameter %d has unsupported type %s", i+1, param))
+		}
+
+		bound.params = append(bound.params, param)
+		bound.paramTypes = append(bound.paramTypes, paramType)
+	}
+
+	results := t.NumOut()
+
+	if results > 0 && t.Out(results-1) == errorType {
+		bound.fails = true
+		results--
+	}
+
+	if results > 1 {
+		return nil, invalidBinding(name, "funcs may return at most one value and an error")
+	}
+
+	if results == 1 {
+		returnType, supported := typeOfResult(t.Out(0))
+
+		if !supported {
+			return nil, invalidBinding(name, fmt.Sprintf("result has unsupported type %s", t.Out(0)))
+		}
+
+		bound.returns = true
+		bound.returnType = returnType
+	}
+
+	return bound, nil
+}
+
+// As Bind, but panics if fn can't be bound.
+func MustBind(name string, fn interface{}) Invokable {
+	bound, err := Bind(name, fn)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return bound
+}
+
+func invalidBinding(name string, reason string) error {
+	return lex.CodedError{Code: "invalid-binding", Message: fmt.Sprintf("Cannot bind %s: %s", name, reason)}
+}
+
+// Gets the type of values that Go values of type t are
+// converted to and from. Values themselves are dynamic.
+func typeOfGo(t reflect.Type) (Type, bool) {
+	if t == valueType {
+		return typeDynamic, true
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return TypeString, true
+	case reflect.Bool:
+		return TypeBoolean, true
+	}
+
+	if isNumeric(t.Kind()) {
+		return TypeNumber, true
+	}
+
+	return typeDynamic, false
+}
+
+// Gets the type of values that Go results of type t are
+// marshalled to. Those that may be nil are dynamic.
+func typeOfResult(t reflect.Type) (Type, bool) {
+	if !canMarshal(t) {
+		return typeDynamic, false
+	}
+
+	if resultType, simple := typeOfGo(t); simple || t.Implements(valueType) {
+		return resultType, true
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return TypeList, true
+	case reflect.Map, reflect.Struct:
+		return TypeRecord, true
+	}
+
+	return typeDynamic, true
+}
+
+func (b *boundFunction) String() string {
+	return fmt.Sprintf("%s() <native>", b.name)
+}
+
+func (b *boundFunction) Type() Type {
+	return TypeInvokable
+}
+
+func (b *boundFunction) ReturnType() Type {
+	return b.returnType
+}
+
+func (b *boundFunction) Invoke(context *Context, args []Value) (err error, result Value) {
+	if len(args) != len(b.params) {
+		return lex.CodedError{
+			Code:    "invalid-arguments",
+			Message: fmt.Sprintf("%s expects %d arguments, got %d", b.name, len(b.params), len(args)),
+		}, nil
+	}
+
+	in := []reflect.Value{}
+
+	if b.context {
+		in = append(in, reflect.ValueOf(context))
+	}
+
+	for i, arg := range args {
+		converted, err := b.argument(i, arg)
+
+		if err != nil {
+			return err, nil
+		}
+
+		in = append(in, converted)
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err, result = lex.CodedError{Code: "native-panic", Message: fmt.Sprintf("%s panicked: %v", b.name, recovered)}, nil
+		}
+	}()
+
+	out := b.fn.Call(in)
+
+	if b.fails {
+		if failure := out[len(out)-1]; !failure.IsNil() {
+			return failure.Interface().(error), nil
+		}
+	}
+
+	if !b.returns {
+		return nil, Void{}
+	}
+
+	// Bind allows only results of types that can be
+	// marshalled, but a result may still contain itself.
+	result, err = marshal(out[0])
+
+	if err != nil {
+		return err, nil
+	}
+
+	return nil, result
+}
+
+// Converts the ith argument to the type of the ith parameter.
+func (b *boundFunction) argument(i int, arg Value) (reflect.Value, error) {
+	param := b.params[i]
+
+	if b.paramTypes[i] == typeDynamic {
+		return reflect.ValueOf(&arg).Elem(), nil
+	}
+
+	if arg.Type() != b.paramTypes[i] {
+		return reflect.Value{}, lex.CodedError{
+			Code:    "invalid-arguments",
+			Message: fmt.Sprintf("Argument %d of %s must be %s, not %s", i+1, b.name, b.paramTypes[i], arg.Type()),
+		}
+	}
+
+	converted := reflect.New(param).Elem()
+
+	switch value := arg.(type) {
+	case String:
+		converted.SetString(value.Value)
+	case Boolean:
+		converted.SetBool(value.Value)
+	case Number:
+		if !setNumber(converted, value.Value) {
+			return reflect.Value{}, lex.CodedError{
+				Code:    "invalid-arguments",
+				Message: fmt.Sprintf("Argument %d of %s must be a whole number in range, not %s", i+1, b.name, value),
+			}
+		}
+	}
+
+	return converted, nil
+}
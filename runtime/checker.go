@@ -0,0 +1,317 @@
+package runtime
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/parse"
+)
+
+// The type of expressions that can't be known until
+// they're evaluated, such as members of modules.
+var typeDynamic = Type("")
+
+// A value of one type given where another was expected.
+type TypeMismatch struct {
+	identifier   string
+	actualType   Type
+	expectedType Type
+	node         parse.Node
+}
+
+func (err TypeMismatch) Error() string {
+	msg := err.message()
+
+	applyPositionToMessage(&msg, err.node)
+
+	return msg
+}
+
+func (err TypeMismatch) message() string {
+	return fmt.Sprintf(
+		`Invalid value for "%s". Value of type %s is not of expected type %s`,
+		err.identifier,
+		err.actualType,
+		err.expectedType,
+	)
+}
+
+func (err TypeMismatch) Location() lex.Position {
+	return locationOf(err.node)
+}
+
+func (err TypeMismatch) Diagnostic() lex.Diagnostic {
+	return diagnosticAt("invalid-type", err.message(), err.node, utf8.RuneCountInString(err.identifier))
+}
+
+type checker struct {
+	table  *SymbolTable
+	types  map[string]Type
+	errors lex.Diagnostics
+}
+
+// Checks the identifiers, declared types and operators
+// used by root, without evaluating it, against the types,
+// functions and operators in table. Returns every problem
+// found as lex.Diagnostics.
+func Check(root parse.RootNode, table *SymbolTable) error {
+	c := &checker{table: table, types: make(map[string]Type)}
+
+	for _, statement := range root.Statements {
+		c.check(statement)
+	}
+
+	if len(c.errors) > 0 {
+		return c.errors
+	}
+
+	return nil
+}
+
+func (c *checker) report(err error, node parse.Node) {
+	c.errors = append(c.errors, lex.Diagnose(lex.At(err, parse.Position(node))))
+}
+
+// Checks node and its children, giving the type it
+// evaluates to.
+func (c *checker) check(node parse.Node) Type {
+	switch n := node.(type) {
+	case *parse.String:
+		return TypeString
+	case *parse.Number:
+		return TypeNumber
+	case *parse.Boolean:
+		return TypeBoolean
+	case *parse.Identifier:
+		return c.checkIdentifier(n)
+	case *parse.FunctionCall:
+		return c.checkFunctionCall(n)
+	case *parse.Operator:
+		if n.Operator == "." {
+			return c.checkMemberAccess(n)
+		}
+
+		return c.checkOperator(n)
+	case *parse.Group:
+		if types := c.checkChildren(n); len(types) == 1 {
+			return types[0]
+		}
+
+		return typeDynamic
+	case *parse.Let:
+		c.checkLet(n)
+	case *parse.Assignment:
+		c.checkAssignment(n)
+	case *parse.If:
+		c.checkIf(n)
+	case *parse.Match:
+		c.checkMatch(n)
+	case *parse.Try:
+		c.checkTry(n)
+	case *parse.Import:
+		c.types[n.Alias.Identifier] = TypeModule
+	case parse.ContainsChildren:
+		c.checkChildren(n)
+	}
+
+	return TypeNone
+}
+
+func (c *checker) checkChildren(parent parse.ContainsChildren) []Type {
+	types := []Type{}
+
+	for _, child := range parent.Children() {
+		types = append(types, c.check(child))
+	}
+
+	return types
+}
+
+// Gets the type of identifier, if it's been declared.
+func (c *checker) lookup(identifier string) (Type, bool) {
+	if t, declared := c.types[identifier]; declared {
+		return t, true
+	}
+
+	if entry, exists := c.table.entries[identifier]; exists {
+		return entry.valueType, true
+	}
+
+	return typeDynamic, false
+}
+
+func (c *checker) checkIdentifier(identifier *parse.Identifier) Type {
+	t, declared := c.lookup(identifier.Identifier)
+
+	if !declared {
+		c.report(UnknownIdentifier{identifier: identifier.Identifier, node: identifier}, identifier)
+	}
+
+	return t
+}
+
+func (c *checker) checkFunctionCall(fn *parse.FunctionCall) Type {
+	c.checkChildren(fn)
+
+	invokable, err := c.table.Invokable(fn.Identifier.Identifier)
+
+	if err != nil {
+		c.report(applyUnknownIdentifierNode(err, *fn.Identifier), fn.Identifier)
+
+		return typeDynamic
+	}
+
+	if typed, isTyped := invokable.(Typed); isTyped {
+		return typed.ReturnType()
+	}
+
+	return typeDynamic
+}
+
+func (c *checker) checkOperator(operator *parse.Operator) Type {
+	operands := Types(c.checkChildren(operator))
+
+	for _, operand := range operands {
+		if operand == typeDynamic {
+			return typeDynamic
+		}
+	}
+
+	invokable, err := c.table.Operator(operator.Operator, operands)
+
+	if err != nil {
+		c.report(applyOperatorNode(err, operator), operator)
+
+		return typeDynamic
+	}
+
+	if typed, isTyped := invokable.(Typed); isTyped {
+		return typed.ReturnType()
+	}
+
+	return typeDynamic
+}
+
+func (c *checker) checkMemberAccess(operator *parse.Operator) Type {
+	children := operator.Children()
+
+	if len(children) != 2 {
+		return typeDynamic
+	}
+
+	member, isIdentifier := children[1].(*parse.Identifier)
+	t := c.check(children[0])
+
+	if !isIdentifier || t == typeDynamic || t == TypeModule || t == TypeRecord {
+		return typeDynamic
+	}
+
+	value := t.DefaultValue()
+
+	if accessible, isAccessible := value.(Accessible); isAccessible {
+		if memberValue, exists := accessible.Member(member.Identifier); exists {
+			return memberValue.Type()
+		}
+	}
+
+	if value != nil {
+		c.report(UnknownMember{member: member.Identifier, value: value, node: member}, member)
+	}
+
+	return typeDynamic
+}
+
+func (c *checker) checkLet(let *parse.Let) {
+	values := c.checkChildren(let)
+
+	t, err := c.table.Type(let.Type.Identifier)
+
+	if err != nil {
+		c.report(applyTypeNode(err, let.Type), let.Type)
+		t = typeDynamic
+	}
+
+	if _, declared := c.lookup(let.Identifier.Identifier); declared {
+		c.report(RedeclaredSymbol{identifier: let.Identifier}, let.Identifier)
+	} else {
+		c.types[let.Identifier.Identifier] = t
+	}
+
+	if len(values) == 1 {
+		c.checkValue(let.Identifier, t, values[0])
+	}
+}
+
+func (c *checker) checkAssignment(assignment *parse.Assignment) {
+	values := c.checkChildren(assignment)
+	t := c.checkIdentifier(assignment.Identifier)
+
+	if len(values) == 1 {
+		c.checkValue(assignment.Identifier, t, values[0])
+	}
+}
+
+// Checks that a value of type actual can be stored in
+// identifier, which has type expected.
+func (c *checker) checkValue(identifier *parse.Identifier, expected Type, actual Type) {
+	if expected == typeDynamic || actual == typeDynamic || expected == actual {
+		return
+	}
+
+	c.report(TypeMismatch{
+		identifier:   identifier.Identifier,
+		actualType:   actual,
+		expectedType: expected,
+		node:         identifier,
+	}, identifier)
+}
+
+func (c *checker) checkIf(node *parse.If) {
+	if t := c.check(node.Condition()); t != typeDynamic && t != TypeBoolean {
+		c.report(lex.CodedError{Code: "invalid-condition", Message: "If condition must evaluate to boolean"}, node)
+	}
+
+	c.checkChildren(node)
+}
+
+func (c *checker) checkMatch(node *parse.Match) {
+	subject := c.check(node.Subject())
+
+	for _, arm := range node.Arms() {
+		for _, valueNode := range arm.Values() {
+			value := c.check(valueNode)
+
+			if subject == typeDynamic || value == typeDynamic {
+				continue
+			}
+
+			if _, err := c.table.Operator("==", Types([]Type{subject, value})); err != nil {
+				c.report(applyOperatorNode(err, valueNode), valueNode)
+			}
+		}
+
+		c.checkChildren(arm)
+	}
+}
+
+func (c *checker) checkTry(node *parse.Try) {
+	c.checkChildren(node)
+
+	if catch := node.Catch(); catch != nil {
+		// As when resolving, the caught error is
+		// only in scope in the catch's block.
+		if _, declared := c.lookup(catch.Identifier.Identifier); declared {
+			c.report(RedeclaredCatch{identifier: catch.Identifier}, catch.Identifier)
+			c.checkChildren(catch)
+		} else {
+			c.types[catch.Identifier.Identifier] = TypeError
+			c.checkChildren(catch)
+			delete(c.types, catch.Identifier.Identifier)
+		}
+	}
+
+	if finally := node.Finally(); finally != nil {
+		c.checkChildren(finally)
+	}
+}
@@ -0,0 +1,67 @@
+package runtime
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A sequence of values, as marshalled from a Go slice
+// or array. Its length is available as its length member.
+type List struct {
+	Values []Value
+}
+
+func (l List) String() string {
+	values := make([]string, len(l.Values))
+
+	for i, value := range l.Values {
+		values[i] = value.String()
+	}
+
+	return fmt.Sprintf("[%s]", strings.Join(values, ", "))
+}
+
+func (l List) Type() Type {
+	return TypeList
+}
+
+func (l List) Member(name string) (Value, bool) {
+	if name == "length" {
+		return Number{Value: float64(len(l.Values))}, true
+	}
+
+	return nil, false
+}
+
+// Named values, as marshalled from a Go struct or a
+// map with string keys. Its fields are its members.
+type Record struct {
+	Fields map[string]Value
+}
+
+func (r Record) String() string {
+	names := make([]string, 0, len(r.Fields))
+
+	for name := range r.Fields {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for i, name := range names {
+		names[i] = fmt.Sprintf("%s: %s", name, r.Fields[name])
+	}
+
+	return fmt.Sprintf("{%s}", strings.Join(names, ", "))
+}
+
+func (r Record) Type() Type {
+	return TypeRecord
+}
+
+func (r Record) Member(name string) (Value, bool) {
+	value, exists := r.Fields[name]
+
+	return value, exists
+}
@@ -0,0 +1,600 @@
+package runtime
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/parse"
+)
+
+type opcode uint8
+
+const (
+	// Pushes constants[a].
+	opConstant opcode = iota
+	// Pushes the value of the variable in slot a.
+	opGet
+	// Declares the variable in slot a, of the type named
+	// by names[b]. If the let has a value, it's popped
+	// and assigned.
+	opLet
+	// Pops a value and assigns it to the variable in slot a.
+	opSet
+	// Pops b arguments and calls the function in slot a,
+	// pushing its result.
+	opCall
+	// Pops b operands and applies the operator names[a]
+	// to them, pushing the result, from call site c.
+	opOperator
+	// Pops a value and pushes its member names[a].
+	opMember
+	opPop
+	// Jumps to a.
+	opJump
+	// Pops a condition and jumps to a if it's false.
+	opJumpIfFalse
+	// Pops a value and jumps to a, popping the subject
+	// beneath it too, if it's equal to the subject as
+	// compared from call site c.
+	opMatch
+	// Pops a value and raises it as an error.
+	opThrow
+	// Imports names[b] as the variable in slot a.
+	opImport
+	// Exports the variable in slot a.
+	opExport
+	// Raises failures[a].
+	opFail
+	// Handles errors raised before the matching opEndTry
+	// by jumping to a, with the error pending.
+	opTry
+	opEndTry
+	// Takes the pending error and assigns it to the variable
+	// in slot a. If b isn't negative, errors raised before the
+	// matching opEndTry are handled by jumping to b.
+	opCatch
+	// Pends no error, for finally blocks entered without one.
+	opNoError
+	// Raises the pending error, if there is one.
+	opEndFinally
+)
+
+var opcodeNames = []string{
+	"constant",
+	"get",
+	"let",
+	"set",
+	"call",
+	"operator",
+	"member",
+	"pop",
+	"jump",
+	"jump-if-false",
+	"match",
+	"throw",
+	"import",
+	"export",
+	"fail",
+	"try",
+	"end-try",
+	"catch",
+	"no-error",
+	"end-finally",
+}
+
+func (op opcode) String() string {
+	return opcodeNames[op]
+}
+
+type instruction struct {
+	op opcode
+	a  int32
+	b  int32
+	c  int32
+}
+
+// Code compiled from a parse tree, ready to be run by a
+// Machine. Identifiers are resolved to slots when compiled,
+// so variables are accessed by index rather than by name.
+// The same Bytecode can be run any number of times.
+type Bytecode struct {
+	instructions []instruction
+	// The node each instruction was compiled from, which
+	// positions errors raised by it.
+	nodes     []parse.Node
+	constants []Value
+	// The identifier of each slot.
+	slots    []string
+	names    []string
+	failures []error
+	// How many operator call sites there are.
+	sites int
+}
+
+// Disassembles the code, one instruction per line.
+func (code *Bytecode) String() string {
+	lines := []string{}
+
+	for pc, instruction := range code.instructions {
+		line := fmt.Sprintf("%4d %-13s", pc, instruction.op)
+
+		switch instruction.op {
+		case opConstant:
+			line += fmt.Sprintf(" %v", code.constants[instruction.a])
+		case opGet, opSet, opExport:
+			line += " " + code.slots[instruction.a]
+		case opLet, opImport:
+			line += fmt.Sprintf(" %s %s", code.slots[instruction.a], code.names[instruction.b])
+		case opCall:
+			line += fmt.Sprintf(" %s %d", code.slots[instruction.a], instruction.b)
+		case opOperator:
+			line += fmt.Sprintf(" %s %d", code.names[instruction.a], instruction.b)
+		case opMember:
+			line += " " + code.names[instruction.a]
+		case opJump, opJumpIfFalse, opMatch, opTry:
+			line += fmt.Sprintf(" %d", instruction.a)
+		case opCatch:
+			line += fmt.Sprintf(" %s %d", code.slots[instruction.a], instruction.b)
+		case opFail:
+			line += " " + code.failures[instruction.a].Error()
+		}
+
+		lines = append(lines, strings.TrimRight(line, " "))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+type compiler struct {
+	code          *Bytecode
+	slotIndex     map[string]int
+	nameIndex     map[string]int
+	constantIndex map[Value]int
+	work          []step
+}
+
+// A step in compiling: to compile node, or to pop the value it
+// pushed, or some other action to take.
+type step struct {
+	node parse.Node
+	pop  bool
+	do   func()
+}
+
+// Compiles node, which is usually a parse.RootNode.
+func Compile(node parse.Node) *Bytecode {
+	c := &compiler{
+		code:          &Bytecode{},
+		slotIndex:     make(map[string]int),
+		nameIndex:     make(map[string]int),
+		constantIndex: make(map[Value]int),
+	}
+
+	// Most nodes compile to one instruction, so this
+	// saves growing the code as it's compiled.
+	size := countNodes(node)
+	c.code.instructions = make([]instruction, 0, size)
+	c.code.nodes = make([]parse.Node, 0, size)
+
+	c.compile(node)
+
+	for len(c.work) > 0 {
+		next := c.work[len(c.work)-1]
+		c.work = c.work[0 : len(c.work)-1]
+
+		if next.do != nil {
+			next.do()
+		} else if next.pop {
+			c.emit(opPop, 0, 0, next.node)
+		} else {
+			c.compile(next.node)
+		}
+	}
+
+	return c.code
+}
+
+func countNodes(root parse.Node) int {
+	count := 0
+	nodes := []parse.Node{root}
+
+	for len(nodes) > 0 {
+		node := nodes[len(nodes)-1]
+		nodes = nodes[0 : len(nodes)-1]
+		count++
+
+		switch n := node.(type) {
+		case parse.RootNode:
+			for _, statement := range n.Statements {
+				nodes = append(nodes, statement)
+			}
+		case *parse.If:
+			nodes = append(nodes, n.Condition())
+		case *parse.Match:
+			nodes = append(nodes, n.Subject())
+
+			for _, arm := range n.Arms() {
+				nodes = append(nodes, arm.Values()...)
+				count += len(arm.Values())
+			}
+		case *parse.Try:
+			if catch := n.Catch(); catch != nil {
+				nodes = append(nodes, catch)
+			}
+
+			if finally := n.Finally(); finally != nil {
+				nodes = append(nodes, finally)
+			}
+		}
+
+		if parent, isParent := node.(parse.ContainsChildren); isParent {
+			nodes = append(nodes, parent.Children()...)
+		}
+	}
+
+	return count
+}
+
+func (c *compiler) emit(op opcode, a int, b int, node parse.Node) int {
+	c.code.instructions = append(c.code.instructions, instruction{op: op, a: int32(a), b: int32(b)})
+	c.code.nodes = append(c.code.nodes, node)
+
+	return len(c.code.instructions) - 1
+}
+
+// As emit, for an instruction that dispatches an
+// operator from its own call site.
+func (c *compiler) emitSite(op opcode, a int, b int, node parse.Node) int {
+	pc := c.emit(op, a, b, node)
+
+	c.code.instructions[pc].c = int32(c.code.sites)
+	c.code.sites++
+
+	return pc
+}
+
+// Points the jump at pc to the next instruction emitted.
+func (c *compiler) patch(pc int) {
+	c.code.instructions[pc].a = int32(len(c.code.instructions))
+}
+
+func (c *compiler) slot(identifier string) int {
+	if slot, exists := c.slotIndex[identifier]; exists {
+		return slot
+	}
+
+	c.slotIndex[identifier] = len(c.code.slots)
+	c.code.slots = append(c.code.slots, identifier)
+
+	return c.slotIndex[identifier]
+}
+
+func (c *compiler) name(name string) int {
+	if index, exists := c.nameIndex[name]; exists {
+		return index
+	}
+
+	c.nameIndex[name] = len(c.code.names)
+	c.code.names = append(c.code.names, name)
+
+	return c.nameIndex[name]
+}
+
+// Pushes value, which must be comparable.
+func (c *compiler) constant(value Value, node parse.Node) {
+	index, exists := c.constantIndex[value]
+
+	if !exists {
+		index = len(c.code.constants)
+		c.constantIndex[value] = index
+		c.code.constants = append(c.code.constants, value)
+	}
+
+	c.emit(opConstant, index, 0, node)
+}
+
+func (c *compiler) fail(err error, node parse.Node) {
+	c.code.failures = append(c.code.failures, err)
+	c.emit(opFail, len(c.code.failures)-1, 0, node)
+}
+
+// Schedules steps to be taken in order, before any others
+// already scheduled. Compiling nested nodes is scheduled
+// rather than done by recursion, so that deeply nested
+// code can't overflow the stack.
+func (c *compiler) then(steps ...step) {
+	for i := len(steps) - 1; i >= 0; i-- {
+		c.work = append(c.work, steps[i])
+	}
+}
+
+func do(action func()) step {
+	return step{do: action}
+}
+
+// The steps to compile each of nodes to a value.
+func (c *compiler) values(nodes []parse.Node) []step {
+	steps := []step{}
+
+	for _, node := range nodes {
+		node := node
+
+		steps = append(steps, step{node: node})
+
+		if !pushesValue(node) {
+			steps = append(steps, do(func() { c.constant(nil, node) }))
+		}
+	}
+
+	return steps
+}
+
+// Whether the code compiled for node pushes
+// a value for it on to the stack.
+func pushesValue(node parse.Node) bool {
+	switch node.(type) {
+	case *parse.String, *parse.Number, *parse.Boolean, *parse.Identifier, *parse.FunctionCall, *parse.Operator, *parse.Group:
+		return true
+	}
+
+	return false
+}
+
+func (c *compiler) compile(node parse.Node) {
+	switch n := node.(type) {
+	case parse.RootNode:
+		statements := []parse.Node{}
+
+		for _, statement := range n.Statements {
+			statements = append(statements, statement)
+		}
+
+		c.compileBlock(statements)
+	case *parse.Statement:
+		c.compileBlock(n.Children())
+	case *parse.String:
+		c.constant(String{Value: n.Value}, n)
+	case *parse.Number:
+		c.constant(Number{Value: n.Value}, n)
+	case *parse.Boolean:
+		c.constant(Boolean{Value: n.Value}, n)
+	case *parse.Identifier:
+		c.emit(opGet, c.slot(n.Identifier), 0, n)
+	case *parse.FunctionCall:
+		c.then(append(c.values(n.Children()), do(func() {
+			c.emit(opCall, c.slot(n.Identifier.Identifier), len(n.Children()), n)
+		}))...)
+	case *parse.Operator:
+		if n.Operator == "." {
+			c.compileMemberAccess(n)
+		} else {
+			c.then(append(c.values(n.Children()), do(func() {
+				c.emitSite(opOperator, c.name(n.Operator), len(n.Children()), n)
+			}))...)
+		}
+	case *parse.Group:
+		c.then(append(c.values(n.Children()), do(func() {
+			if len(n.Children()) != 1 {
+				c.fail(lex.CodedError{Code: "invalid-group", Message: fmt.Sprintf("Group should not have more than 1 child, actually has: %d", len(n.Children()))}, n)
+			}
+		}))...)
+	case *parse.Let:
+		c.compileLet(n)
+	case *parse.Assignment:
+		c.then(append(c.values(n.Children()), do(func() {
+			if len(n.Children()) != 1 {
+				c.fail(lex.CodedError{Code: "invalid-assignment", Message: "Assignment must have at exactly one value"}, n)
+			} else {
+				c.emit(opSet, c.slot(n.Identifier.Identifier), 0, n)
+			}
+		}))...)
+	case *parse.If:
+		var jump int
+
+		c.then(append(
+			c.values([]parse.Node{n.Condition()}),
+			do(func() {
+				jump = c.emit(opJumpIfFalse, 0, 0, n)
+				c.compileBlock(n.Children())
+			}),
+			do(func() { c.patch(jump) }),
+		)...)
+	case *parse.Match:
+		c.compileMatch(n)
+	case *parse.Try:
+		c.compileTry(n)
+	case *parse.Throw:
+		c.then(append(c.values(n.Children()), do(func() {
+			if len(n.Children()) != 1 {
+				c.fail(lex.CodedError{Code: "invalid-throw", Message: "Throw must have exactly one value"}, n)
+			} else {
+				c.emit(opThrow, 0, 0, n)
+			}
+		}))...)
+	case *parse.Import:
+		c.emit(opImport, c.slot(n.Alias.Identifier), c.name(n.Path.Value), n)
+	case *parse.Export:
+		c.then(
+			do(func() { c.compileBlock(n.Children()) }),
+			do(func() {
+				for _, child := range n.Children() {
+					if let, isLet := child.(*parse.Let); isLet {
+						c.emit(opExport, c.slot(let.Identifier.Identifier), 0, n)
+					}
+				}
+			}),
+		)
+	default:
+		c.fail(lex.CodedError{Code: "not-implemented", Message: fmt.Sprintf("Handling for %#v not yet implemented.", node)}, node)
+	}
+}
+
+// Compiles statements, discarding any values they push.
+func (c *compiler) compileBlock(statements []parse.Node) {
+	steps := []step{}
+
+	for _, statement := range statements {
+		steps = append(steps, step{node: statement})
+
+		if pushesValue(statement) {
+			steps = append(steps, step{node: statement, pop: true})
+		}
+	}
+
+	c.then(steps...)
+}
+
+func (c *compiler) compileMemberAccess(operator *parse.Operator) {
+	children := operator.Children()
+
+	if len(children) != 2 {
+		c.fail(lex.CodedError{Code: "invalid-member-access", Message: "Member access requires a value and a member"}, operator)
+
+		return
+	}
+
+	member, isIdentifier := children[1].(*parse.Identifier)
+
+	if !isIdentifier {
+		c.fail(lex.CodedError{Code: "invalid-member-access", Message: "Member access requires a member identifier"}, operator)
+
+		return
+	}
+
+	c.then(append(c.values(children[0:1]), do(func() {
+		c.emit(opMember, c.name(member.Identifier), 0, member)
+	}))...)
+}
+
+func (c *compiler) compileLet(let *parse.Let) {
+	c.then(append(c.values(let.Children()), do(func() {
+		if len(let.Children()) > 1 {
+			c.fail(lex.CodedError{Code: "invalid-assignment", Message: "Assignment with declaration must have at most one value"}, let)
+		} else {
+			c.emit(opLet, c.slot(let.Identifier.Identifier), c.name(let.Type.Identifier), let)
+		}
+	}))...)
+}
+
+// Compiles a match to test each arm's values against the
+// subject in turn, jumping to the body of the first arm
+// with a value equal to it.
+func (c *compiler) compileMatch(match *parse.Match) {
+	arms := match.Arms()
+	jumps := make([][]int, len(arms))
+	ends := []int{}
+
+	steps := c.values([]parse.Node{match.Subject()})
+
+	for i, arm := range arms {
+		i, arm := i, arm
+
+		if arm.Wildcard() {
+			steps = append(steps, do(func() {
+				c.emit(opPop, 0, 0, arm)
+				jumps[i] = append(jumps[i], c.emit(opJump, 0, 0, arm))
+			}))
+		}
+
+		for _, value := range arm.Values() {
+			value := value
+
+			steps = append(append(steps, c.values([]parse.Node{value})...), do(func() {
+				jumps[i] = append(jumps[i], c.emitSite(opMatch, 0, 0, value))
+			}))
+		}
+	}
+
+	steps = append(steps, do(func() {
+		c.emit(opPop, 0, 0, match)
+		ends = append(ends, c.emit(opJump, 0, 0, match))
+	}))
+
+	for i, arm := range arms {
+		i, arm := i, arm
+
+		steps = append(
+			steps,
+			do(func() {
+				for _, jump := range jumps[i] {
+					c.patch(jump)
+				}
+
+				c.compileBlock(arm.Children())
+			}),
+			do(func() { ends = append(ends, c.emit(opJump, 0, 0, arm)) }),
+		)
+	}
+
+	steps = append(steps, do(func() {
+		for _, end := range ends {
+			c.patch(end)
+		}
+	}))
+
+	c.then(steps...)
+}
+
+// Compiles a try so that errors raised in its block jump to its
+// catch, if it has one, otherwise its finally. The finally is
+// entered with the error that ended the try or catch pending,
+// or none, and raises it once it's done.
+func (c *compiler) compileTry(try *parse.Try) {
+	catch, finally := try.Catch(), try.Finally()
+
+	if catch == nil && finally == nil {
+		c.compileBlock(try.Children())
+
+		return
+	}
+
+	var handler, end int
+
+	steps := []step{
+		do(func() {
+			handler = c.emit(opTry, 0, 0, try)
+			c.compileBlock(try.Children())
+		}),
+		do(func() { c.emit(opEndTry, 0, 0, try) }),
+	}
+
+	if catch != nil {
+		steps = append(
+			steps,
+			do(func() {
+				end = c.emit(opJump, 0, 0, try)
+				c.patch(handler)
+				handler = c.emit(opCatch, c.slot(catch.Identifier.Identifier), -1, catch)
+				c.compileBlock(catch.Children())
+			}),
+			do(func() {
+				if finally != nil {
+					c.emit(opEndTry, 0, 0, catch)
+				}
+
+				c.patch(end)
+			}),
+		)
+	}
+
+	if finally != nil {
+		steps = append(
+			steps,
+			do(func() {
+				c.emit(opNoError, 0, 0, finally)
+
+				if catch != nil {
+					c.code.instructions[handler].b = int32(len(c.code.instructions))
+				} else {
+					c.patch(handler)
+				}
+
+				c.compileBlock(finally.Children())
+			}),
+			do(func() { c.emit(opEndFinally, 0, 0, finally) }),
+		)
+	}
+
+	c.then(steps...)
+}
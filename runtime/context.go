@@ -5,8 +5,12 @@ import (
 )
 
 type Context struct {
-	Table  *SymbolTable
-	Input  io.Reader
-	Output io.Writer
-	Error  io.Writer
+	Table   *SymbolTable
+	Input   io.Reader
+	Output  io.Writer
+	Error   io.Writer
+	File    string
+	Modules *Modules
+	// Limits evaluation, if it's been given limits.
+	budget *budget
 }
@@ -1,28 +1,52 @@
 package runtime
 
 import (
+	"context"
 	"io"
 
-	"errors"
 	"fmt"
 
+	"github.com/ehimen/jaslang/lex"
 	"github.com/ehimen/jaslang/parse"
 )
 
 type Evaluator interface {
 	Evaluate(parse.Node) error
+	// As Evaluate, but stops once ctx is done or any of
+	// limits is exceeded, with Cancelled or LimitExceeded.
+	EvaluateContext(ctx context.Context, node parse.Node, limits Limits) error
 }
 
 type evaluator struct {
 	context *Context
+	// Each operator's, and match value's, call site,
+	// by the index it's resolved to.
+	sites []callSite
 }
 
 func NewEvaluator(input io.Reader, output io.Writer, error io.Writer) Evaluator {
+	return newEvaluator(&Context{Table: NewBuiltinTable(), Input: input, Output: output, Error: error, Modules: NewModules(nil)})
+}
+
+// Creates an evaluator that evaluates code in context,
+// with its table, I/O and modules.
+func NewContextEvaluator(context *Context) Evaluator {
+	return newEvaluator(context)
+}
+
+func newEvaluator(context *Context) *evaluator {
+	return &evaluator{context: context}
+}
+
+// Creates a table with all native types,
+// functions and operators.
+func NewBuiltinTable() *SymbolTable {
 	table := NewTable()
 
 	table.AddType("string", TypeString)
 	table.AddType("boolean", TypeBoolean)
 	table.AddType("number", TypeNumber)
+	table.AddType("error", TypeError)
 
 	table.AddFunction("println", Println{})
 	table.AddOperator("+", Types([]Type{TypeNumber, TypeNumber}), AddNumbers{})
@@ -33,137 +57,432 @@ func NewEvaluator(input io.Reader, output io.Writer, error io.Writer) Evaluator
 	table.AddOperator("&&", Types([]Type{TypeBoolean, TypeBoolean}), LogicAnd{})
 	table.AddOperator("||", Types([]Type{TypeBoolean, TypeBoolean}), LogicOr{})
 	table.AddOperator("==", Types([]Type{TypeNumber, TypeNumber}), Equality{})
+	table.AddOperator("==", Types([]Type{TypeString, TypeString}), Equality{})
+	table.AddOperator("==", Types([]Type{TypeBoolean, TypeBoolean}), Equality{})
 	table.AddOperator("<", Types([]Type{TypeNumber, TypeNumber}), LessThan{})
 	table.AddOperator(">", Types([]Type{TypeNumber, TypeNumber}), GreaterThan{})
 
-	return &evaluator{context: &Context{Table: table, Input: input, Output: output, Error: error}}
+	return table
 }
 
+// Evaluates node. Any error is a lex.Diagnostic, or the
+// parse.Errors of a module that couldn't be imported.
 func (e *evaluator) Evaluate(node parse.Node) error {
+	if err := Resolve(node, e.context.Table); err != nil {
+		return err
+	}
 
-	if err, _ := e.evaluate(node); err != nil {
+	return e.run(node)
+}
+
+func (e *evaluator) EvaluateContext(ctx context.Context, node parse.Node, limits Limits) error {
+	if err := Resolve(node, e.context.Table); err != nil {
 		return err
 	}
 
+	return e.runContext(ctx, node, limits)
+}
+
+// Evaluates node, which has already been resolved, as Evaluate.
+func (e *evaluator) run(node parse.Node) error {
+	if err, _ := e.evaluate(node); err != nil {
+		if errs, isErrors := err.(parse.Errors); isErrors {
+			return errs
+		}
+
+		return lex.Diagnose(err)
+	}
+
 	return nil
 }
 
+// As run, but limited as EvaluateContext.
+func (e *evaluator) runContext(ctx context.Context, node parse.Node, limits Limits) error {
+	e.context.budget = newBudget(ctx, limits)
+
+	defer func() {
+		e.context.budget = nil
+	}()
+
+	return e.run(node)
+}
+
+// A node being evaluated. Tasks are kept on the evaluator's
+// own stack, rather than Go's, so that deeply nested code
+// can't overflow it.
+type task struct {
+	node parse.Node
+	// How far through evaluating the node the task is.
+	state int
+	// The next of the node's children, or statements
+	// of the current block, to evaluate.
+	next int
+	args []Value
+	// For matches, the subject and the arm and value
+	// that it's being compared with.
+	subject Value
+	arm     int
+	value   int
+	// For tries, the error to raise once finally is done.
+	err error
+}
+
+// Evaluates node, positioning any error that doesn't
+// know where it was raised at the innermost node being
+// evaluated when it was.
 func (e *evaluator) evaluate(node parse.Node) (error, Value) {
-	args := []Value{}
+	stack := []task{{node: node}}
 
-	if i, isIf := node.(*parse.If); isIf {
-		return e.evaluateIf(i), nil
-	}
+	var err error
+	var value Value
 
-	if parent, isParent := node.(parse.ContainsChildren); isParent {
-		for _, child := range parent.Children() {
-			// TODO: not recursion to avoid stack overflows.
-			if err, arg := e.evaluate(child); err != nil {
-				return err, nil
-			} else {
-				args = append(args, arg)
+	for len(stack) > 0 {
+		t := &stack[len(stack)-1]
+
+		if next := e.step(t, &err, &value); next != nil {
+			if spendErr := e.context.budget.spend(); spendErr != nil {
+				// Nothing can handle this, so no need to unwind.
+				return lex.At(spendErr, parse.Position(next)), nil
 			}
+
+			stack = append(stack, task{node: next})
+			err, value = nil, nil
+
+			continue
 		}
-	} else if root, isRoot := node.(parse.RootNode); isRoot {
-		for _, child := range root.Statements {
-			// TODO: not recursion to avoid stack overflows.
-			if err, arg := e.evaluate(child); err != nil {
-				return err, nil
-			} else {
-				args = append(args, arg)
-			}
+
+		if err != nil && t.node.Line() > 0 {
+			err = lex.At(err, parse.Position(t.node))
 		}
+
+		stack = stack[0 : len(stack)-1]
 	}
 
-	if str, isStr := node.(*parse.String); isStr {
-		return nil, String{Value: str.Value}
+	return err, value
+}
+
+// Steps t on, given the result of the last node it asked
+// to be evaluated, if any. Gives the next node to evaluate,
+// or nil if t has finished with the result in err and value.
+func (e *evaluator) step(t *task, err *error, value *Value) parse.Node {
+	switch n := t.node.(type) {
+	case *parse.If:
+		return e.stepIf(t, n, err, value)
+	case *parse.Match:
+		return e.stepMatch(t, n, err, value)
+	case *parse.Try:
+		return e.stepTry(t, n, err)
+	case *parse.Operator:
+		if n.Operator == "." {
+			return e.stepMemberAccess(t, n, err, value)
+		}
 	}
 
-	if num, isNum := node.(*parse.Number); isNum {
-		return nil, Number{Value: num.Value}
+	if *err != nil {
+		return nil
 	}
 
-	if boolean, isBool := node.(*parse.Boolean); isBool {
-		return nil, Boolean{Value: boolean.Value}
+	if t.state > 0 {
+		t.args = append(t.args, *value)
 	}
 
-	if fn, isFn := node.(*parse.FunctionCall); isFn {
-		return e.evaluateFunctionCall(fn, args)
+	var children []parse.Node
+
+	if parent, isParent := t.node.(parse.ContainsChildren); isParent {
+		children = parent.Children()
+	} else if root, isRoot := t.node.(parse.RootNode); isRoot && t.next < len(root.Statements) {
+		return t.evaluateNext(root.Statements[t.next])
 	}
 
-	if operator, isOperator := node.(*parse.Operator); isOperator {
-		return e.evaluateOperator(operator, args)
+	if t.next < len(children) {
+		return t.evaluateNext(children[t.next])
 	}
 
-	if let, isLet := node.(*parse.Let); isLet {
-		return e.evaluateLet(let, args)
+	*err, *value = e.evaluateNode(t.node, t.args)
+
+	return nil
+}
+
+// Evaluates the next of t's children.
+func (t *task) evaluateNext(child parse.Node) parse.Node {
+	t.state = 1
+	t.next++
+
+	return child
+}
+
+// Steps through statements as a block, giving the next to
+// evaluate, or nil once they're done or one has failed.
+func (t *task) stepBlock(statements []parse.Node, err error) parse.Node {
+	if err != nil || t.next >= len(statements) {
+		return nil
 	}
 
-	if identifier, isIdentifier := node.(*parse.Identifier); isIdentifier {
-		return e.evaluateIdentifier(identifier, args)
+	t.next++
+
+	return statements[t.next-1]
+}
+
+func (e *evaluator) stepIf(t *task, node *parse.If, err *error, value *Value) parse.Node {
+	switch t.state {
+	case 0:
+		t.state = 1
+
+		return node.Condition()
+	case 1:
+		if *err != nil {
+			return nil
+		}
+
+		if boolValue, isBool := (*value).(Boolean); !isBool {
+			*err = lex.CodedError{Code: "invalid-condition", Message: "If condition must evaluate to boolean"}
+
+			return nil
+		} else if !boolValue.Value {
+			*value = nil
+
+			return nil
+		}
+
+		t.state = 2
 	}
 
-	if assignment, isAssignment := node.(*parse.Assignment); isAssignment {
-		return e.evaluateAssignment(assignment, args)
+	*value = nil
+
+	return t.stepBlock(node.Children(), *err)
+}
+
+// Steps through each arm's values, comparing them with the
+// subject, until one is equal or the arm is a wildcard. That
+// arm's block is then evaluated.
+func (e *evaluator) stepMatch(t *task, node *parse.Match, err *error, value *Value) parse.Node {
+	arms := node.Arms()
+
+	switch t.state {
+	case 0:
+		t.state = 1
+
+		return node.Subject()
+	case 1:
+		if *err != nil {
+			return nil
+		}
+
+		t.subject, t.arm, t.value, t.state = *value, 0, -1, 2
+	case 2:
+		if *err != nil {
+			return nil
+		}
+
+		valueNode := arms[t.arm].Values()[t.value]
+
+		if matches, matchErr := e.matches(t.subject, *value, valueNode, arms[t.arm].Site(t.value)); matchErr != nil {
+			*err = matchErr
+
+			return nil
+		} else if matches {
+			t.state = 3
+		}
 	}
 
-	if _, isGroup := node.(*parse.Group); isGroup {
-		if len(args) != 1 {
-			return errors.New(fmt.Sprintf("Group should not have more than 1 child, actually has: %d", len(args))), nil
+	for t.state == 2 && t.arm < len(arms) {
+		if arms[t.arm].Wildcard() {
+			t.state = 3
+
+			break
 		}
 
-		return nil, args[0]
+		if t.value++; t.value < len(arms[t.arm].Values()) {
+			return arms[t.arm].Values()[t.value]
+		}
+
+		t.arm, t.value = t.arm+1, -1
 	}
 
-	// Nothing to do with statements/root as these are AST constructs (for now).
-	if _, isStmt := node.(*parse.Statement); isStmt {
-		return nil, nil
+	*value = nil
+
+	if t.state != 3 {
+		return nil
 	}
 
-	if _, isRoot := node.(parse.RootNode); isRoot {
+	return t.stepBlock(arms[t.arm].Children(), *err)
+}
+
+// Evaluates a try's block. An error from any statement
+// in it is caught as an error value by the catch block,
+// if there is one. The finally block is always evaluated;
+// an error it raises takes the place of any other.
+func (e *evaluator) stepTry(t *task, node *parse.Try, err *error) parse.Node {
+	const (
+		inTry = iota
+		inCatch
+		inFinally
+	)
+
+	catch, finally := node.Catch(), node.Finally()
+
+	if *err != nil && stops(*err) {
+		return nil
+	}
+
+	if t.state == inTry {
+		if next := t.stepBlock(node.Children(), *err); next != nil {
+			return next
+		}
+
+		if *err != nil && catch != nil {
+			e.context.Table.catch(catch.Identifier, NewError(*err, node.Children()[t.next-1]))
+			t.state, t.next, *err = inCatch, 0, nil
+		} else {
+			t.state, t.next, t.err, *err = inFinally, 0, *err, nil
+		}
+	}
+
+	if t.state == inCatch {
+		if next := t.stepBlock(catch.Children(), *err); next != nil {
+			return next
+		}
+
+		t.state, t.next, t.err, *err = inFinally, 0, *err, nil
+	}
+
+	if finally != nil {
+		if next := t.stepBlock(finally.Children(), *err); next != nil {
+			return next
+		}
+
+		if *err != nil {
+			return nil
+		}
+	}
+
+	*err = t.err
+
+	return nil
+}
+
+func (e *evaluator) stepMemberAccess(t *task, operator *parse.Operator, err *error, value *Value) parse.Node {
+	children := operator.Children()
+
+	if len(children) != 2 {
+		*err = lex.CodedError{Code: "invalid-member-access", Message: "Member access requires a value and a member"}
+
+		return nil
+	}
+
+	member, isIdentifier := children[1].(*parse.Identifier)
+
+	if !isIdentifier {
+		*err = lex.CodedError{Code: "invalid-member-access", Message: "Member access requires a member identifier"}
+
+		return nil
+	}
+
+	if t.state == 0 {
+		t.state = 1
+
+		return children[0]
+	}
+
+	if *err != nil {
+		return nil
+	}
+
+	if accessible, isAccessible := (*value).(Accessible); isAccessible {
+		if memberValue, exists := accessible.Member(member.Identifier); exists {
+			*value = memberValue
+
+			return nil
+		}
+	}
+
+	*err = UnknownMember{member: member.Identifier, value: *value, node: member}
+
+	return nil
+}
+
+// Evaluates node, once its children have been evaluated to args.
+func (e *evaluator) evaluateNode(node parse.Node, args []Value) (error, Value) {
+	switch n := node.(type) {
+	case *parse.String:
+		return nil, String{Value: n.Value}
+	case *parse.Number:
+		return nil, Number{Value: n.Value}
+	case *parse.Boolean:
+		return nil, Boolean{Value: n.Value}
+	case *parse.FunctionCall:
+		return e.evaluateFunctionCall(n, args)
+	case *parse.Operator:
+		return e.evaluateOperator(n, args)
+	case *parse.Let:
+		return e.evaluateLet(n, args)
+	case *parse.Identifier:
+		return e.evaluateIdentifier(n, args)
+	case *parse.Assignment:
+		return e.evaluateAssignment(n, args)
+	case *parse.Throw:
+		return e.evaluateThrow(n, args), nil
+	case *parse.Import:
+		return e.evaluateImport(n), nil
+	case *parse.Export:
+		return e.evaluateExport(n), nil
+	case *parse.Group:
+		if len(args) != 1 {
+			return lex.CodedError{Code: "invalid-group", Message: fmt.Sprintf("Group should not have more than 1 child, actually has: %d", len(args))}, nil
+		}
+
+		return nil, args[0]
+	case *parse.Statement, parse.RootNode:
+		// Nothing to do with statements/root as these are AST constructs (for now).
 		return nil, nil
 	}
 
-	return errors.New(fmt.Sprintf("Handling for %#v not yet implemented.", node)), nil
+	return lex.CodedError{Code: "not-implemented", Message: fmt.Sprintf("Handling for %#v not yet implemented.", node)}, nil
 }
 
 func (e *evaluator) evaluateFunctionCall(fn *parse.FunctionCall, args []Value) (error, Value) {
-	if invokable, err := e.context.Table.Invokable(fn.Identifier.Identifier); err != nil {
-		return err, nil
-	} else {
-		invokable.Invoke(e.context, args)
+	invokable, err := e.context.Table.invokableBound(fn.Identifier)
+
+	if err != nil {
+		return applyUnknownIdentifierNode(err, *fn.Identifier), nil
 	}
 
-	return nil, nil
+	return invokable.Invoke(e.context, args)
 }
 
 func (e *evaluator) evaluateOperator(operator *parse.Operator, args []Value) (error, Value) {
-	operands := Types([]Type{})
+	invokable, err := e.context.Table.dispatch(e.site(operator.Site), operator.Operator, args)
 
-	for _, arg := range args {
-		operands = append(operands, arg.Type())
+	if err != nil {
+		return applyOperatorNode(err, operator), nil
 	}
 
-	if invokable, err := e.context.Table.Operator(operator.Operator, operands); err != nil {
-		if unknownOperator, isUnknownOperator := err.(UnknownOperator); isUnknownOperator {
-			unknownOperator.node = operator
+	err, result := invokable.Invoke(e.context, args)
 
-			return unknownOperator, nil
-		}
+	if err == nil {
+		err = e.context.budget.allocate(result)
+	}
 
-		return err, nil
-	} else {
-		return invokable.Invoke(e.context, args)
+	return err, result
+}
+
+// Gets the call site resolved to index, making room for
+// it if it's the first evaluated of those resolved since.
+func (e *evaluator) site(index int) *callSite {
+	if index >= len(e.sites) {
+		e.sites = append(e.sites, make([]callSite, index+1-len(e.sites))...)
 	}
+
+	return &e.sites[index]
 }
 
 func (e *evaluator) evaluateLet(let *parse.Let, args []Value) (error, Value) {
 	if len(args) > 1 {
-		return errors.New("Assignment with declaration must have at most one value"), nil
+		return lex.CodedError{Code: "invalid-assignment", Message: "Assignment with declaration must have at most one value"}, nil
 	}
 
 	if valueType, err := e.context.Table.Type(let.Type.Identifier); err != nil {
-		return err, nil
+		return applyTypeNode(err, let.Type), nil
 	} else if err := e.context.Table.Define(let.Identifier.Identifier, valueType); err != nil {
 		return err, nil
 	} else if len(args) == 1 {
@@ -174,32 +493,75 @@ func (e *evaluator) evaluateLet(let *parse.Let, args []Value) (error, Value) {
 }
 
 func (e *evaluator) evaluateIdentifier(identifier *parse.Identifier, args []Value) (error, Value) {
-	val, err := e.context.Table.Get(identifier.Identifier)
+	val, err := e.context.Table.getBound(identifier)
 
 	return applyUnknownIdentifierNode(err, *identifier), val
 }
 
 func (e *evaluator) evaluateAssignment(assignment *parse.Assignment, args []Value) (error, Value) {
 	if len(args) != 1 {
-		return errors.New("Assignment must have at exactly one value"), nil
+		return lex.CodedError{Code: "invalid-assignment", Message: "Assignment must have at exactly one value"}, nil
 	}
 
 	return e.setValue(*assignment.Identifier, args[0]), nil
 }
 
-func (e *evaluator) evaluateIf(node *parse.If) error {
-	err, result := e.evaluate(node.Condition())
+// Whether value is equal to subject, as determined
+// by the == operator for their types. A value of
+// another type than subject's never matches it.
+func (e *evaluator) matches(subject Value, value Value, valueNode parse.Node, site int) (bool, error) {
+	if subject.Type() != value.Type() {
+		return false, nil
+	}
+
+	equality, err := e.context.Table.dispatch(e.site(site), "==", []Value{subject, value})
+
+	if err != nil {
+		return false, applyOperatorNode(err, valueNode)
+	}
+
+	if err, result := equality.Invoke(e.context, []Value{subject, value}); err != nil {
+		return false, err
+	} else if equal, isBool := result.(Boolean); isBool && equal.Value {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (e *evaluator) evaluateThrow(node *parse.Throw, args []Value) error {
+	if len(args) != 1 {
+		return lex.CodedError{Code: "invalid-throw", Message: "Throw must have exactly one value"}
+	}
+
+	if value, isError := args[0].(Error); isError {
+		return value
+	}
+
+	return Error{Message: args[0].String(), File: node.File(), Line: node.Line(), Column: node.Column()}
+}
+
+func (e *evaluator) evaluateImport(node *parse.Import) error {
+	module, err := e.context.Modules.Import(node.Path.Value, node, e.context)
 
 	if err != nil {
 		return err
 	}
 
-	if boolValue, isBool := result.(Boolean); !isBool {
-		return errors.New("If condition must evaluate to boolean")
-	} else {
-		if boolValue.Value {
-			for _, child := range node.ParentNode.Children() {
-				e.evaluate(child)
+	if err := e.context.Table.DefineValue(node.Alias.Identifier, module); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Exports everything declared by the export's children,
+// which have already been evaluated.
+func (e *evaluator) evaluateExport(node *parse.Export) error {
+	for _, child := range node.Children() {
+		if let, isLet := child.(*parse.Let); isLet {
+			if err := e.context.Table.Export(let.Identifier.Identifier); err != nil {
+				return err
 			}
 		}
 	}
@@ -208,7 +570,7 @@ func (e *evaluator) evaluateIf(node *parse.If) error {
 }
 
 func (e evaluator) setValue(identifier parse.Identifier, value Value) error {
-	err := e.context.Table.Set(identifier.Identifier, value)
+	err := e.context.Table.setBound(&identifier, value)
 
 	if invalidType, isInvalidType := err.(InvalidType); isInvalidType {
 		invalidType.node = identifier
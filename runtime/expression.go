@@ -0,0 +1,67 @@
+package runtime
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/parse"
+)
+
+// An expression, such as "total * 2 > limit", compiled once
+// so that it can be evaluated many times with different
+// variables. It doesn't change once compiled, so it may be
+// evaluated from many goroutines at once.
+type Expression struct {
+	code  *Bytecode
+	table *SymbolTable
+}
+
+// Compiles node, which must be an expression such as
+// parse.ParseExpression gives, to be evaluated with the
+// types, operators and functions of table. Operators on
+// literals are folded, as Optimise does.
+func CompileExpression(node parse.Node, table *SymbolTable) (*Expression, error) {
+	node = Optimise(node, table)
+
+	if !pushesValue(node) {
+		return nil, lex.At(lex.CodedError{Code: "not-an-expression", Message: "Only expressions can be evaluated for their value"}, locationOf(node))
+	}
+
+	return &Expression{code: Compile(node), table: table.Fresh()}, nil
+}
+
+// Evaluates the expression with variables, by identifier,
+// stopping once ctx is done or any of limits is exceeded.
+// Its functions have no input and their output is discarded.
+func (e *Expression) Evaluate(ctx context.Context, variables map[string]Value, limits Limits) (Value, error) {
+	table := e.table.Fresh()
+
+	for identifier, value := range variables {
+		if err := table.DefineValue(identifier, value); err != nil {
+			return nil, err
+		}
+	}
+
+	machine := newMachine(&Context{
+		Table:   table,
+		Input:   strings.NewReader(""),
+		Output:  ioutil.Discard,
+		Error:   ioutil.Discard,
+		Modules: NewCompilingModules(nil),
+	})
+
+	f, err := machine.execute(ctx, e.code, limits)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return f.pop(), nil
+}
+
+// Disassembles the expression's code, as Bytecode does.
+func (e *Expression) String() string {
+	return e.code.String()
+}
@@ -1,6 +1,6 @@
 package runtime
 
-import "errors"
+import "github.com/ehimen/jaslang/lex"
 
 type AddNumbers struct {
 }
@@ -13,6 +13,10 @@ func (a AddNumbers) Type() Type {
 	return TypeInvokable
 }
 
+func (a AddNumbers) ReturnType() Type {
+	return TypeNumber
+}
+
 func (a AddNumbers) Invoke(context *Context, args []Value) (error, Value) {
 	if one, isNumber := args[0].(Number); isNumber {
 		if two, isNumber := args[1].(Number); isNumber {
@@ -20,7 +24,7 @@ func (a AddNumbers) Invoke(context *Context, args []Value) (error, Value) {
 		}
 	}
 
-	return errors.New("Invalid operands. Number addition requires two numbers"), nil
+	return lex.CodedError{Code: "invalid-operands", Message: "Invalid operands. Number addition requires two numbers"}, nil
 }
 
 type SubtractNumbers struct {
@@ -34,6 +38,10 @@ func (a SubtractNumbers) Type() Type {
 	return TypeInvokable
 }
 
+func (a SubtractNumbers) ReturnType() Type {
+	return TypeNumber
+}
+
 func (a SubtractNumbers) Invoke(context *Context, args []Value) (error, Value) {
 	if one, isNumber := args[0].(Number); isNumber {
 		if two, isNumber := args[1].(Number); isNumber {
@@ -41,7 +49,7 @@ func (a SubtractNumbers) Invoke(context *Context, args []Value) (error, Value) {
 		}
 	}
 
-	return errors.New("Invalid operands. Subtraction requires two numbers"), nil
+	return lex.CodedError{Code: "invalid-operands", Message: "Invalid operands. Subtraction requires two numbers"}, nil
 }
 
 type MultiplyNumbers struct {
@@ -55,6 +63,10 @@ func (m MultiplyNumbers) Type() Type {
 	return TypeInvokable
 }
 
+func (m MultiplyNumbers) ReturnType() Type {
+	return TypeNumber
+}
+
 func (m MultiplyNumbers) Invoke(context *Context, args []Value) (error, Value) {
 	if one, isNumber := args[0].(Number); isNumber {
 		if two, isNumber := args[1].(Number); isNumber {
@@ -62,7 +74,7 @@ func (m MultiplyNumbers) Invoke(context *Context, args []Value) (error, Value) {
 		}
 	}
 
-	return errors.New("Invalid operands. Multiplication requires two numbers"), nil
+	return lex.CodedError{Code: "invalid-operands", Message: "Invalid operands. Multiplication requires two numbers"}, nil
 }
 
 type DivideNumbers struct {
@@ -76,6 +88,10 @@ func (d DivideNumbers) Type() Type {
 	return TypeInvokable
 }
 
+func (d DivideNumbers) ReturnType() Type {
+	return TypeNumber
+}
+
 func (d DivideNumbers) Invoke(context *Context, args []Value) (error, Value) {
 	if one, isNumber := args[0].(Number); isNumber {
 		if two, isNumber := args[1].(Number); isNumber {
@@ -83,5 +99,5 @@ func (d DivideNumbers) Invoke(context *Context, args []Value) (error, Value) {
 		}
 	}
 
-	return errors.New("Invalid operands. Division requires two numbers"), nil
+	return lex.CodedError{Code: "invalid-operands", Message: "Invalid operands. Division requires two numbers"}, nil
 }
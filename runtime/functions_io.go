@@ -11,6 +11,10 @@ func (p Println) Type() Type {
 	return TypeInvokable
 }
 
+func (p Println) ReturnType() Type {
+	return TypeNone
+}
+
 func (p Println) Invoke(context *Context, args []Value) (error, Value) {
 	for _, arg := range args {
 		context.Output.Write([]byte(arg.String() + "\n"))
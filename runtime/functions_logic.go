@@ -1,6 +1,6 @@
 package runtime
 
-import "errors"
+import "github.com/ehimen/jaslang/lex"
 
 type LogicAnd struct {
 }
@@ -13,6 +13,10 @@ func (l LogicAnd) Type() Type {
 	return TypeInvokable
 }
 
+func (l LogicAnd) ReturnType() Type {
+	return TypeBoolean
+}
+
 func (l LogicAnd) Invoke(context *Context, args []Value) (error, Value) {
 	if one, isBoolean := args[0].(Boolean); isBoolean {
 		if two, isBoolean := args[1].(Boolean); isBoolean {
@@ -20,7 +24,7 @@ func (l LogicAnd) Invoke(context *Context, args []Value) (error, Value) {
 		}
 	}
 
-	return errors.New("Invalid operands. Logic AND requires two booleans"), nil
+	return lex.CodedError{Code: "invalid-operands", Message: "Invalid operands. Logic AND requires two booleans"}, nil
 }
 
 type LogicOr struct {
@@ -34,6 +38,10 @@ func (l LogicOr) Type() Type {
 	return TypeInvokable
 }
 
+func (l LogicOr) ReturnType() Type {
+	return TypeBoolean
+}
+
 func (l LogicOr) Invoke(context *Context, args []Value) (error, Value) {
 	if one, isBoolean := args[0].(Boolean); isBoolean {
 		if two, isBoolean := args[1].(Boolean); isBoolean {
@@ -41,7 +49,7 @@ func (l LogicOr) Invoke(context *Context, args []Value) (error, Value) {
 		}
 	}
 
-	return errors.New("Invalid operands. Logic OR requires two booleans"), nil
+	return lex.CodedError{Code: "invalid-operands", Message: "Invalid operands. Logic OR requires two booleans"}, nil
 }
 
 type Equality struct {
@@ -55,15 +63,27 @@ func (l Equality) Type() Type {
 	return TypeInvokable
 }
 
+func (l Equality) ReturnType() Type {
+	return TypeBoolean
+}
+
 func (l Equality) Invoke(context *Context, args []Value) (error, Value) {
-	if one, isNumber := args[0].(Number); isNumber {
+	switch one := args[0].(type) {
+	case Number:
 		if two, isNumber := args[1].(Number); isNumber {
 			return nil, Boolean{Value: one.Value == two.Value}
 		}
+	case String:
+		if two, isString := args[1].(String); isString {
+			return nil, Boolean{Value: one.Value == two.Value}
+		}
+	case Boolean:
+		if two, isBoolean := args[1].(Boolean); isBoolean {
+			return nil, Boolean{Value: one.Value == two.Value}
+		}
 	}
 
-	// TODO: we should be able to perform equality for any kind of operator.
-	return errors.New("Invalid operands. Equality requires two numbers"), nil
+	return lex.CodedError{Code: "invalid-operands", Message: "Invalid operands. Equality requires two values of the same type"}, nil
 }
 
 type LessThan struct {
@@ -77,6 +97,10 @@ func (l LessThan) Type() Type {
 	return TypeInvokable
 }
 
+func (l LessThan) ReturnType() Type {
+	return TypeBoolean
+}
+
 func (l LessThan) Invoke(context *Context, args []Value) (error, Value) {
 	if one, isNumber := args[0].(Number); isNumber {
 		if two, isNumber := args[1].(Number); isNumber {
@@ -84,7 +108,7 @@ func (l LessThan) Invoke(context *Context, args []Value) (error, Value) {
 		}
 	}
 
-	return errors.New("Invalid operands. Less than comparison requires two numbers"), nil
+	return lex.CodedError{Code: "invalid-operands", Message: "Invalid operands. Less than comparison requires two numbers"}, nil
 }
 
 type GreaterThan struct {
@@ -98,6 +122,10 @@ func (l GreaterThan) Type() Type {
 	return TypeInvokable
 }
 
+func (l GreaterThan) ReturnType() Type {
+	return TypeBoolean
+}
+
 func (l GreaterThan) Invoke(context *Context, args []Value) (error, Value) {
 	if one, isNumber := args[0].(Number); isNumber {
 		if two, isNumber := args[1].(Number); isNumber {
@@ -105,5 +133,5 @@ func (l GreaterThan) Invoke(context *Context, args []Value) (error, Value) {
 		}
 	}
 
-	return errors.New("Invalid operands. Greater than comparison requires two numbers"), nil
+	return lex.CodedError{Code: "invalid-operands", Message: "Invalid operands. Greater than comparison requires two numbers"}, nil
 }
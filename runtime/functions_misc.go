@@ -7,6 +7,10 @@ func (n Noop) Type() Type {
 	return TypeInvokable
 }
 
+func (n Noop) ReturnType() Type {
+	return TypeNone
+}
+
 func (n Noop) String() string {
 	return "noop <native>"
 }
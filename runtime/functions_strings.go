@@ -1,6 +1,6 @@
 package runtime
 
-import "errors"
+import "github.com/ehimen/jaslang/lex"
 
 type StringConcatenation struct {
 }
@@ -13,6 +13,10 @@ func (p StringConcatenation) Type() Type {
 	return TypeInvokable
 }
 
+func (p StringConcatenation) ReturnType() Type {
+	return TypeString
+}
+
 func (p StringConcatenation) Invoke(context *Context, args []Value) (error, Value) {
 	if one, isString := args[0].(String); isString {
 		if two, isString := args[1].(String); isString {
@@ -20,5 +24,5 @@ func (p StringConcatenation) Invoke(context *Context, args []Value) (error, Valu
 		}
 	}
 
-	return errors.New("Invalid operands. String concatenation requires two strings"), nil
+	return lex.CodedError{Code: "invalid-operands", Message: "Invalid operands. String concatenation requires two strings"}, nil
 }
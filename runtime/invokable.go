@@ -4,3 +4,10 @@ type Invokable interface {
 	Value
 	Invoke(context *Context, args []Value) (error, Value)
 }
+
+// Implemented by invokables that always return the same
+// type, so that their results can be checked before
+// they're evaluated.
+type Typed interface {
+	ReturnType() Type
+}
@@ -0,0 +1,167 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/parse"
+)
+
+// Bounds the work that evaluation may do, so that code
+// that can't be trusted can't exhaust its host. A zero
+// limit is no limit, but for ImportDepth.
+type Limits struct {
+	// How many nodes may be evaluated or, by a Machine,
+	// how many instructions may be run.
+	Nodes int
+	// How deeply modules may import one another, each
+	// evaluated a level deeper than the one importing it.
+	// The language has no functions of its own, so imports
+	// are the only way evaluation nests. It's always
+	// bounded, by DefaultImportDepth if it's zero.
+	ImportDepth int
+	// How long values that grow may be. Strings are the
+	// only such values so far, measured in bytes.
+	CollectionSize int
+}
+
+// How deeply modules may import one another by default.
+// Each module being loaded is evaluated inside the one
+// importing it, so the depth is bounded to keep that safe.
+const DefaultImportDepth = 100
+
+type Limit string
+
+const (
+	LimitNodes          Limit = "nodes"
+	LimitImportDepth    Limit = "import-depth"
+	LimitCollectionSize Limit = "collection-size"
+)
+
+// Evaluation exceeded one of its Limits. It can't be
+// caught, so evaluation stops when it's raised.
+type LimitExceeded struct {
+	Limit Limit
+	Max   int
+	node  parse.Node
+}
+
+func (err LimitExceeded) Error() string {
+	msg := err.message()
+
+	applyPositionToMessage(&msg, err.node)
+
+	return msg
+}
+
+func (err LimitExceeded) message() string {
+	switch err.Limit {
+	case LimitNodes:
+		return fmt.Sprintf("Evaluated more than %d nodes", err.Max)
+	case LimitImportDepth:
+		return fmt.Sprintf("Import depth of %d exceeded", err.Max)
+	}
+
+	return fmt.Sprintf("Collection size of %d exceeded", err.Max)
+}
+
+func (err LimitExceeded) Location() lex.Position {
+	return locationOf(err.node)
+}
+
+func (err LimitExceeded) Diagnostic() lex.Diagnostic {
+	return diagnosticAt("limit-exceeded", err.message(), err.node, 1)
+}
+
+// Evaluation was stopped because its context was done.
+// As LimitExceeded, it can't be caught. It unwraps to the
+// context's error.
+type Cancelled struct {
+	err error
+}
+
+func (err Cancelled) Error() string {
+	return fmt.Sprintf("Evaluation stopped: %s", err.err)
+}
+
+func (err Cancelled) Unwrap() error {
+	return err.err
+}
+
+func (err Cancelled) Diagnostic() lex.Diagnostic {
+	return diagnosticAt("cancelled", err.Error(), nil, 0)
+}
+
+// What's been spent of the limits of an evaluation,
+// shared by every module it imports.
+type budget struct {
+	done   <-chan struct{}
+	ctx    context.Context
+	limits Limits
+	nodes  int
+}
+
+func newBudget(ctx context.Context, limits Limits) *budget {
+	return &budget{done: ctx.Done(), ctx: ctx, limits: limits}
+}
+
+// Spends a node, failing if too many have been or the
+// evaluation has been cancelled. A nil budget is unlimited.
+func (b *budget) spend() error {
+	if b == nil {
+		return nil
+	}
+
+	select {
+	case <-b.done:
+		return Cancelled{err: b.ctx.Err()}
+	default:
+	}
+
+	if b.nodes++; b.limits.Nodes > 0 && b.nodes > b.limits.Nodes {
+		return LimitExceeded{Limit: LimitNodes, Max: b.limits.Nodes}
+	}
+
+	return nil
+}
+
+// Checks that value isn't too large.
+func (b *budget) allocate(value Value) error {
+	if b == nil || b.limits.CollectionSize == 0 {
+		return nil
+	}
+
+	if str, isString := value.(String); isString && len(str.Value) > b.limits.CollectionSize {
+		return LimitExceeded{Limit: LimitCollectionSize, Max: b.limits.CollectionSize}
+	}
+
+	return nil
+}
+
+// Checks that importing another module, depth modules deep,
+// wouldn't nest evaluation too deeply. Unlike the other
+// limits, a nil budget still bounds the depth.
+func (b *budget) nest(depth int, node parse.Node) error {
+	max := DefaultImportDepth
+
+	if b != nil && b.limits.ImportDepth > 0 {
+		max = b.limits.ImportDepth
+	}
+
+	if depth < max {
+		return nil
+	}
+
+	return LimitExceeded{Limit: LimitImportDepth, Max: max, node: node}
+}
+
+// Whether err stops evaluation, rather than
+// being catchable by the code being evaluated.
+func stops(err error) bool {
+	var exceeded LimitExceeded
+	var cancelled Cancelled
+
+	return errors.As(err, &exceeded) || errors.As(err, &cancelled)
+}
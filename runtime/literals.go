@@ -1,6 +1,12 @@
 package runtime
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/parse"
+)
 
 type Type string
 
@@ -9,6 +15,10 @@ var TypeBoolean = Type("boolean")
 var TypeNumber = Type("number")
 var TypeString = Type("string")
 var TypeInvokable = Type("invokable")
+var TypeError = Type("error")
+var TypeModule = Type("module")
+var TypeList = Type("list")
+var TypeRecord = Type("record")
 
 func (t Type) DefaultValue() Value {
 	switch t {
@@ -20,6 +30,12 @@ func (t Type) DefaultValue() Value {
 		return String{Value: ""}
 	case TypeInvokable:
 		return Noop{}
+	case TypeError:
+		return Error{}
+	case TypeList:
+		return List{}
+	case TypeRecord:
+		return Record{}
 	}
 
 	return nil
@@ -27,21 +43,14 @@ func (t Type) DefaultValue() Value {
 
 type Types []Type
 
+// Whether types are the same as other, in the same order.
 func (types Types) Equal(other Types) bool {
 	if len(types) != len(other) {
 		return false
 	}
 
-	for _, t1 := range types {
-		found := false
-
-		for _, t2 := range other {
-			if t1 == t2 {
-				found = true
-			}
-		}
-
-		if !found {
+	for i, t := range types {
+		if t != other[i] {
 			return false
 		}
 	}
@@ -84,3 +93,81 @@ func (b Boolean) String() string {
 func (b Boolean) Type() Type {
 	return TypeBoolean
 }
+
+// An error as a value, as thrown by throw or
+// caught by catch. Errors are also Go errors so
+// they can be returned through the evaluator.
+type Error struct {
+	Message string
+	File    string
+	Line    int
+	Column  int
+}
+
+// Creates an error value from err. Where err doesn't know
+// where it was raised, the position of fallback is used.
+func NewError(err error, fallback parse.Node) Error {
+	var value Error
+
+	if errors.As(err, &value) {
+		return value
+	}
+
+	diagnostic := lex.Diagnose(err)
+	position := diagnostic.Start
+
+	if position.Line == 0 {
+		position = locationOf(fallback)
+	}
+
+	return Error{
+		Message: diagnostic.Message,
+		File:    position.File,
+		Line:    position.Line,
+		Column:  position.Column,
+	}
+}
+
+func (e Error) Error() string {
+	return e.String()
+}
+
+func (e Error) String() string {
+	if e.Line == 0 {
+		return e.Message
+	}
+
+	return fmt.Sprintf("%s: %s", e.Location(), e.Message)
+}
+
+func (e Error) Location() lex.Position {
+	return lex.Position{File: e.File, Line: e.Line, Column: e.Column}
+}
+
+func (e Error) Diagnostic() lex.Diagnostic {
+	return lex.Diagnostic{
+		Severity: lex.SeverityError,
+		Code:     "uncaught-error",
+		Message:  e.Message,
+		Span:     lex.SpanAt(e.Location(), 1),
+	}
+}
+
+func (e Error) Type() Type {
+	return TypeError
+}
+
+func (e Error) Member(name string) (Value, bool) {
+	switch name {
+	case "message":
+		return String{Value: e.Message}, true
+	case "file":
+		return String{Value: e.File}, true
+	case "line":
+		return Number{Value: float64(e.Line)}, true
+	case "column":
+		return Number{Value: float64(e.Column)}, true
+	}
+
+	return nil, false
+}
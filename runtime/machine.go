@@ -0,0 +1,408 @@
+package runtime
+
+import (
+	"context"
+	"io"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/parse"
+)
+
+// A stack-based virtual machine that runs Bytecode. It's an
+// Evaluator that compiles the nodes it's given before running
+// them, and is much faster than the tree-walking evaluator
+// for code that isn't dominated by parsing.
+type Machine struct {
+	context *Context
+}
+
+// Where to go when an error is raised, and the
+// state to return to when going there.
+type handler struct {
+	target  int
+	stack   int
+	pending int
+}
+
+// The state of one run of some Bytecode.
+type frame struct {
+	code *Bytecode
+	// The table entry of each slot, once it's been declared.
+	entries  []*entry
+	stack    []Value
+	handlers []handler
+	// Errors being handled by catch and finally blocks.
+	pending []error
+	sites   []callSite
+}
+
+func NewMachine(input io.Reader, output io.Writer, error io.Writer) *Machine {
+	return newMachine(&Context{Table: NewBuiltinTable(), Input: input, Output: output, Error: error, Modules: NewCompilingModules(nil)})
+}
+
+// As NewContextEvaluator, for a Machine.
+func NewContextMachine(context *Context) *Machine {
+	return newMachine(context)
+}
+
+func newMachine(context *Context) *Machine {
+	return &Machine{context: context}
+}
+
+// Resolves, compiles and runs node. Errors are as for
+// the tree-walking evaluator.
+func (m *Machine) Evaluate(node parse.Node) error {
+	if err := Resolve(node, m.context.Table); err != nil {
+		return err
+	}
+
+	return m.Run(Compile(node))
+}
+
+// As Evaluate, but stops once ctx is done or any of
+// limits is exceeded, with Cancelled or LimitExceeded.
+func (m *Machine) EvaluateContext(ctx context.Context, node parse.Node, limits Limits) error {
+	if err := Resolve(node, m.context.Table); err != nil {
+		return err
+	}
+
+	return m.RunContext(ctx, Compile(node), limits)
+}
+
+// As Run, but limited as EvaluateContext.
+func (m *Machine) RunContext(ctx context.Context, code *Bytecode, limits Limits) error {
+	_, err := m.execute(ctx, code, limits)
+
+	return err
+}
+
+// Runs code, which may have been compiled once and run
+// on many machines. Any error is a lex.Diagnostic, or the
+// parse.Errors of a module that couldn't be imported.
+func (m *Machine) Run(code *Bytecode) error {
+	_, err := m.start(code)
+
+	return err
+}
+
+// Runs code as RunContext, giving the frame it ran in
+// so that what it left on the stack can be had.
+func (m *Machine) execute(ctx context.Context, code *Bytecode, limits Limits) (*frame, error) {
+	m.context.budget = newBudget(ctx, limits)
+
+	defer func() {
+		m.context.budget = nil
+	}()
+
+	return m.start(code)
+}
+
+// Runs code in a new frame, as Run, giving the frame.
+func (m *Machine) start(code *Bytecode) (*frame, error) {
+	f := &frame{code: code, entries: make([]*entry, len(code.slots)), sites: make([]callSite, code.sites)}
+
+	if err := m.run(f); err != nil {
+		if errs, isErrors := err.(parse.Errors); isErrors {
+			return f, errs
+		}
+
+		return f, lex.Diagnose(err)
+	}
+
+	return f, nil
+}
+
+func (m *Machine) run(f *frame) error {
+	code := f.code
+
+	for pc := 0; pc < len(code.instructions); pc++ {
+		err := m.context.budget.spend()
+
+		if err == nil {
+			err = m.step(f, &pc)
+		}
+
+		if err != nil {
+			if node := code.nodes[pc]; node != nil && node.Line() > 0 {
+				err = lex.At(err, parse.Position(node))
+			}
+
+			if len(f.handlers) == 0 || stops(err) {
+				return err
+			}
+
+			h := f.handlers[len(f.handlers)-1]
+
+			f.handlers = f.handlers[0 : len(f.handlers)-1]
+			f.stack = f.stack[0:h.stack]
+			f.pending = append(f.pending[0:h.pending], err)
+
+			pc = h.target - 1
+		}
+	}
+
+	return nil
+}
+
+// Executes the instruction at pc, leaving pc at the
+// last instruction executed.
+func (m *Machine) step(f *frame, pc *int) error {
+	code := f.code
+	instruction := code.instructions[*pc]
+	a, b, c := int(instruction.a), int(instruction.b), int(instruction.c)
+
+	switch instruction.op {
+	case opConstant:
+		f.push(code.constants[a])
+	case opGet:
+		return m.get(f, a, code.nodes[*pc])
+	case opLet:
+		return m.let(f, a, code.names[b], code.nodes[*pc].(*parse.Let))
+	case opSet:
+		return m.set(f, a, f.pop(), *code.nodes[*pc].(*parse.Assignment).Identifier)
+	case opCall:
+		return m.call(f, a, b)
+	case opOperator:
+		return m.operator(f, code.names[a], b, &f.sites[c], code.nodes[*pc])
+	case opMember:
+		value := f.pop()
+
+		if accessible, isAccessible := value.(Accessible); isAccessible {
+			if member, exists := accessible.Member(code.names[a]); exists {
+				f.push(member)
+
+				return nil
+			}
+		}
+
+		return UnknownMember{member: code.names[a], value: value, node: code.nodes[*pc]}
+	case opPop:
+		f.pop()
+	case opJump:
+		*pc = a - 1
+	case opJumpIfFalse:
+		if condition, isBool := f.pop().(Boolean); !isBool {
+			return lex.CodedError{Code: "invalid-condition", Message: "If condition must evaluate to boolean"}
+		} else if !condition.Value {
+			*pc = a - 1
+		}
+	case opMatch:
+		return m.match(f, a, &f.sites[c], code.nodes[*pc], pc)
+	case opThrow:
+		value := f.pop()
+
+		if err, isError := value.(Error); isError {
+			return err
+		}
+
+		node := code.nodes[*pc]
+
+		return Error{Message: value.String(), File: node.File(), Line: node.Line(), Column: node.Column()}
+	case opImport:
+		module, err := m.context.Modules.Import(code.names[b], code.nodes[*pc], m.context)
+
+		if err != nil {
+			return err
+		}
+
+		if err := m.context.Table.DefineValue(code.slots[a], module); err != nil {
+			return err
+		}
+
+		f.entries[a] = m.context.Table.entries[code.slots[a]]
+	case opExport:
+		return m.context.Table.Export(code.slots[a])
+	case opFail:
+		return code.failures[a]
+	case opTry:
+		f.handlers = append(f.handlers, handler{target: a, stack: len(f.stack), pending: len(f.pending)})
+	case opEndTry:
+		f.handlers = f.handlers[0 : len(f.handlers)-1]
+	case opCatch:
+		caught := f.pending[len(f.pending)-1]
+		f.pending = f.pending[0 : len(f.pending)-1]
+
+		if b >= 0 {
+			f.handlers = append(f.handlers, handler{target: b, stack: len(f.stack), pending: len(f.pending)})
+		}
+
+		m.catch(f, a, NewError(caught, code.nodes[*pc]), code.nodes[*pc].(*parse.Catch).Identifier)
+	case opNoError:
+		f.pending = append(f.pending, nil)
+	case opEndFinally:
+		err := f.pending[len(f.pending)-1]
+		f.pending = f.pending[0 : len(f.pending)-1]
+
+		return err
+	}
+
+	return nil
+}
+
+func (f *frame) push(value Value) {
+	f.stack = append(f.stack, value)
+}
+
+func (f *frame) pop() Value {
+	value := f.stack[len(f.stack)-1]
+	f.stack = f.stack[0 : len(f.stack)-1]
+
+	return value
+}
+
+// Gets the table entry of slot, if it's been declared.
+// Entries are never removed, so once found it's kept.
+func (m *Machine) entry(f *frame, slot int) (*entry, bool) {
+	if found := f.entries[slot]; found != nil {
+		return found, true
+	}
+
+	found, exists := m.context.Table.entries[f.code.slots[slot]]
+
+	if exists {
+		f.entries[slot] = found
+	}
+
+	return found, exists
+}
+
+func (m *Machine) get(f *frame, slot int, node parse.Node) error {
+	found, exists := m.entry(f, slot)
+
+	if !exists {
+		return UnknownIdentifier{identifier: f.code.slots[slot], node: node}
+	}
+
+	// As the evaluator, an uninitialised symbol gives no value.
+	f.push(found.value)
+
+	return nil
+}
+
+func (m *Machine) let(f *frame, slot int, typeName string, let *parse.Let) error {
+	var value Value
+
+	if len(let.Children()) == 1 {
+		value = f.pop()
+	}
+
+	valueType, err := m.context.Table.Type(typeName)
+
+	if err != nil {
+		return applyTypeNode(err, let.Type)
+	}
+
+	if err := m.context.Table.Define(f.code.slots[slot], valueType); err != nil {
+		return err
+	}
+
+	// The slot may hold an error caught before the let.
+	f.entries[slot] = m.context.Table.entries[f.code.slots[slot]]
+
+	if len(let.Children()) == 1 {
+		return m.set(f, slot, value, *let.Identifier)
+	}
+
+	return nil
+}
+
+func (m *Machine) set(f *frame, slot int, value Value, identifier parse.Identifier) error {
+	found, exists := m.entry(f, slot)
+
+	if !exists {
+		return UnknownIdentifier{identifier: identifier.Identifier, node: identifier}
+	}
+
+	if value.Type() != found.valueType {
+		return InvalidType{identifier: identifier.Identifier, value: value, expectedType: string(found.valueType), node: identifier}
+	}
+
+	found.value = value
+
+	return nil
+}
+
+func (m *Machine) call(f *frame, slot int, argc int) error {
+	args := make([]Value, argc)
+	copy(args, f.stack[len(f.stack)-argc:])
+	f.stack = f.stack[0 : len(f.stack)-argc]
+
+	found, exists := m.entry(f, slot)
+
+	if !exists {
+		return UnknownIdentifier{identifier: f.code.slots[slot]}
+	}
+
+	invokable, isInvokable := found.value.(Invokable)
+
+	if !isInvokable {
+		return UnknownIdentifier{identifier: f.code.slots[slot]}
+	}
+
+	err, result := invokable.Invoke(m.context, args)
+
+	if err != nil {
+		return err
+	}
+
+	f.push(result)
+
+	return nil
+}
+
+func (m *Machine) operator(f *frame, operator string, argc int, site *callSite, node parse.Node) error {
+	args := make([]Value, argc)
+	copy(args, f.stack[len(f.stack)-argc:])
+	f.stack = f.stack[0 : len(f.stack)-argc]
+
+	invokable, err := m.context.Table.dispatch(site, operator, args)
+
+	if err != nil {
+		return applyOperatorNode(err, node)
+	}
+
+	err, result := invokable.Invoke(m.context, args)
+
+	if err == nil {
+		err = m.context.budget.allocate(result)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	f.push(result)
+
+	return nil
+}
+
+func (m *Machine) match(f *frame, target int, site *callSite, node parse.Node, pc *int) error {
+	value := f.pop()
+	subject := f.stack[len(f.stack)-1]
+	args := []Value{subject, value}
+
+	if subject.Type() != value.Type() {
+		return nil
+	}
+
+	equality, err := m.context.Table.dispatch(site, "==", args)
+
+	if err != nil {
+		return applyOperatorNode(err, node)
+	}
+
+	if err, result := equality.Invoke(m.context, args); err != nil {
+		return err
+	} else if equal, isBool := result.(Boolean); isBool && equal.Value {
+		f.pop()
+		*pc = target - 1
+	}
+
+	return nil
+}
+
+// Holds caught in the frame's slot alone, as the
+// evaluator does, so only the catch block sees it.
+func (m *Machine) catch(f *frame, slot int, caught Error, identifier *parse.Identifier) {
+	f.entries[slot] = &entry{identifier: identifier.Identifier, valueType: TypeError, value: caught}
+}
@@ -0,0 +1,452 @@
+package runtime
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/ehimen/jaslang/lex"
+)
+
+// Converts v to a Value, much as encoding/json converts
+// Go values to JSON. Strings, bools and numbers of any
+// kind become their Values, slices and arrays become
+// Lists, and structs and maps with string keys become
+// Records. A struct field's name in its Record may be
+// set by a jsl tag, and fields tagged "-" or unexported
+// are left out. Pointers and interfaces are converted
+// to what they point to, or to Void if nil, and Values
+// are left as they are. Values that contain themselves,
+// such as a struct pointing back to itself, can't be
+// marshalled.
+func Marshal(v interface{}) (Value, error) {
+	return marshal(reflect.ValueOf(v))
+}
+
+func marshal(v reflect.Value) (Value, error) {
+	return (&marshaller{visiting: make(map[visit]bool)}).marshal(v)
+}
+
+// Whether values of type t can be marshalled, as long
+// as they don't contain themselves. Interfaces can't be
+// told until their values are known, so are allowed.
+func canMarshal(t reflect.Type) bool {
+	return canMarshalAll(t, make(map[reflect.Type]bool))
+}
+
+// As canMarshal, but types in checking are being checked
+// already, so are allowed to contain themselves.
+func canMarshalAll(t reflect.Type, checking map[reflect.Type]bool) bool {
+	if checking[t] || t.Implements(valueType) {
+		return true
+	}
+
+	checking[t] = true
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return canMarshalAll(t.Elem(), checking)
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && canMarshalAll(t.Elem(), checking)
+	case reflect.Struct:
+		for _, field := range fieldsOf(t) {
+			if !canMarshalAll(t.Field(field.index).Type, checking) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return isNumeric(t.Kind())
+}
+
+// Marshals a value, keeping track of the pointers, maps and
+// slices it's inside of so that it can tell when a value
+// contains itself, as encoding/json does.
+type marshaller struct {
+	visiting map[visit]bool
+}
+
+// What's pointed to by a pointer, map or slice. Slices
+// are told apart by length as well as where they start.
+type visit struct {
+	pointer uintptr
+	length  int
+	t       reflect.Type
+}
+
+func (m *marshaller) marshal(v reflect.Value) (Value, error) {
+	if !v.IsValid() {
+		return Void{}, nil
+	}
+
+	if v.Kind() != reflect.Ptr && v.Kind() != reflect.Interface && v.Type().Implements(valueType) {
+		return v.Interface().(Value), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return String{Value: v.String()}, nil
+	case reflect.Bool:
+		return Boolean{Value: v.Bool()}, nil
+	case reflect.Float32, reflect.Float64:
+		return Number{Value: v.Float()}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Number{Value: float64(v.Int())}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Number{Value: float64(v.Uint())}, nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return Void{}, nil
+		}
+
+		if value, isValue := v.Interface().(Value); isValue {
+			return value, nil
+		}
+
+		if v.Kind() == reflect.Ptr {
+			if err := m.enter(v, 0); err != nil {
+				return nil, err
+			}
+
+			defer m.leave(v, 0)
+		}
+
+		return m.marshal(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && !v.IsNil() {
+			if err := m.enter(v, v.Len()); err != nil {
+				return nil, err
+			}
+
+			defer m.leave(v, v.Len())
+		}
+
+		list := List{Values: make([]Value, v.Len())}
+
+		for i := range list.Values {
+			value, err := m.marshal(v.Index(i))
+
+			if err != nil {
+				return nil, err
+			}
+
+			list.Values[i] = value
+		}
+
+		return list, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, cannotMarshal(v.Type())
+		}
+
+		if !v.IsNil() {
+			if err := m.enter(v, 0); err != nil {
+				return nil, err
+			}
+
+			defer m.leave(v, 0)
+		}
+
+		record := Record{Fields: make(map[string]Value, v.Len())}
+
+		for _, key := range v.MapKeys() {
+			value, err := m.marshal(v.MapIndex(key))
+
+			if err != nil {
+				return nil, err
+			}
+
+			record.Fields[key.String()] = value
+		}
+
+		return record, nil
+	case reflect.Struct:
+		record := Record{Fields: make(map[string]Value)}
+
+		for _, field := range fieldsOf(v.Type()) {
+			value, err := m.marshal(v.Field(field.index))
+
+			if err != nil {
+				return nil, err
+			}
+
+			record.Fields[field.name] = value
+		}
+
+		return record, nil
+	}
+
+	return nil, cannotMarshal(v.Type())
+}
+
+// Marks v, a non-nil pointer, map or slice, as being marshalled,
+// failing if it already is as the value then contains itself.
+func (m *marshaller) enter(v reflect.Value, length int) error {
+	visited := visit{v.Pointer(), length, v.Type()}
+
+	if m.visiting[visited] {
+		return lex.CodedError{Code: "cannot-marshal", Message: fmt.Sprintf("Cannot marshal %s, as it contains itself", v.Type())}
+	}
+
+	m.visiting[visited] = true
+
+	return nil
+}
+
+func (m *marshaller) leave(v reflect.Value, length int) {
+	delete(m.visiting, visit{v.Pointer(), length, v.Type()})
+}
+
+// Sets what v points to from value, reversing Marshal.
+// Records set only the struct fields they have, and
+// numbers set integers only if whole and in range.
+// Into an empty interface, value is set as a string,
+// bool, float64, []interface{} or map[string]interface{},
+// and into a Value, as itself.
+func Unmarshal(value Value, v interface{}) error {
+	target := reflect.ValueOf(v)
+
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return lex.CodedError{Code: "cannot-unmarshal", Message: fmt.Sprintf("Cannot unmarshal into %T, which isn't a non-nil pointer", v)}
+	}
+
+	return unmarshal(value, target.Elem())
+}
+
+func unmarshal(value Value, v reflect.Value) error {
+	if value == nil {
+		value = Void{}
+	}
+
+	if v.Type() == valueType {
+		v.Set(reflect.ValueOf(&value).Elem())
+
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if _, isVoid := value.(Void); isVoid {
+			v.Set(reflect.Zero(v.Type()))
+
+			return nil
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+
+		return unmarshal(value, v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			if goValue := goValueOf(value); goValue == nil {
+				v.Set(reflect.Zero(v.Type()))
+			} else {
+				v.Set(reflect.ValueOf(goValue))
+			}
+
+			return nil
+		}
+	}
+
+	switch value := value.(type) {
+	case String:
+		if v.Kind() == reflect.String {
+			v.SetString(value.Value)
+
+			return nil
+		}
+	case Boolean:
+		if v.Kind() == reflect.Bool {
+			v.SetBool(value.Value)
+
+			return nil
+		}
+	case Number:
+		if isNumeric(v.Kind()) {
+			if !setNumber(v, value.Value) {
+				return lex.CodedError{Code: "cannot-unmarshal", Message: fmt.Sprintf("Cannot unmarshal %s into %s, as it isn't whole or is out of range", value, v.Type())}
+			}
+
+			return nil
+		}
+	case List:
+		return unmarshalList(value, v)
+	case Record:
+		return unmarshalRecord(value, v)
+	}
+
+	return cannotUnmarshal(value, v.Type())
+}
+
+func unmarshalList(list List, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), len(list.Values), len(list.Values)))
+	case reflect.Array:
+		if v.Len() < len(list.Values) {
+			return lex.CodedError{Code: "cannot-unmarshal", Message: fmt.Sprintf("Cannot unmarshal a list of %d values into %s", len(list.Values), v.Type())}
+		}
+
+		v.Set(reflect.Zero(v.Type()))
+	default:
+		return cannotUnmarshal(list, v.Type())
+	}
+
+	for i, value := range list.Values {
+		if err := unmarshal(value, v.Index(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func unmarshalRecord(record Record, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return cannotUnmarshal(record, v.Type())
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(record.Fields)))
+		}
+
+		for name, value := range record.Fields {
+			element := reflect.New(v.Type().Elem()).Elem()
+
+			if err := unmarshal(value, element); err != nil {
+				return err
+			}
+
+			v.SetMapIndex(reflect.ValueOf(name).Convert(v.Type().Key()), element)
+		}
+
+		return nil
+	case reflect.Struct:
+		for _, field := range fieldsOf(v.Type()) {
+			if value, exists := record.Fields[field.name]; exists {
+				if err := unmarshal(value, v.Field(field.index)); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	return cannotUnmarshal(record, v.Type())
+}
+
+// Converts value to the Go value it's
+// unmarshalled to in an empty interface.
+func goValueOf(value Value) interface{} {
+	switch value := value.(type) {
+	case String:
+		return value.Value
+	case Boolean:
+		return value.Value
+	case Number:
+		return value.Value
+	case List:
+		values := make([]interface{}, len(value.Values))
+
+		for i, element := range value.Values {
+			values[i] = goValueOf(element)
+		}
+
+		return values
+	case Record:
+		fields := make(map[string]interface{}, len(value.Fields))
+
+		for name, field := range value.Fields {
+			fields[name] = goValueOf(field)
+		}
+
+		return fields
+	case Void:
+		return nil
+	}
+
+	return value
+}
+
+// A struct field as it appears in a Record.
+type recordField struct {
+	name  string
+	index int
+}
+
+// Gets the fields of the struct type t that are in its Records.
+func fieldsOf(t reflect.Type) []recordField {
+	fields := []recordField{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("jsl")
+
+		if field.PkgPath != "" || name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		fields = append(fields, recordField{name: name, index: i})
+	}
+
+	return fields
+}
+
+func isNumeric(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+
+	return false
+}
+
+// Sets the number v to n, failing if v is an integer
+// and n isn't whole or doesn't fit in it.
+func setNumber(v reflect.Value, n float64) bool {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if !whole(n, 0, math.MaxUint64) || v.OverflowUint(uint64(n)) {
+			return false
+		}
+
+		v.SetUint(uint64(n))
+	default:
+		if !whole(n, math.MinInt64, math.MaxInt64) || v.OverflowInt(int64(n)) {
+			return false
+		}
+
+		v.SetInt(int64(n))
+	}
+
+	return true
+}
+
+// Whether n is a whole number from min to max.
+func whole(n float64, min float64, max float64) bool {
+	return n == math.Trunc(n) && n >= min && n < max
+}
+
+func cannotMarshal(t reflect.Type) error {
+	return lex.CodedError{Code: "cannot-marshal", Message: fmt.Sprintf("Cannot marshal %s", t)}
+}
+
+func cannotUnmarshal(value Value, t reflect.Type) error {
+	return lex.CodedError{Code: "cannot-unmarshal", Message: fmt.Sprintf("Cannot unmarshal %s into %s", value.Type(), t)}
+}
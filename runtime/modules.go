@@ -0,0 +1,243 @@
+package runtime
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/parse"
+)
+
+// A loaded module. Its exports are accessed
+// as members, e.g. lib.value.
+type Module struct {
+	file  string
+	table *SymbolTable
+}
+
+func (m *Module) String() string {
+	return fmt.Sprintf("<module %s>", m.file)
+}
+
+func (m *Module) Type() Type {
+	return TypeModule
+}
+
+func (m *Module) Member(name string) (Value, bool) {
+	return m.table.Exported(name)
+}
+
+// Loads modules from files. Each module is evaluated
+// once, with its own symbol table, no matter how many
+// times it is imported.
+type Modules struct {
+	searchPath []string
+	compile    bool
+	loaded     map[string]*Module
+	loading    []string
+}
+
+type ImportNotFound struct {
+	path string
+	node parse.Node
+}
+
+func (err ImportNotFound) Error() string {
+	msg := err.message()
+
+	applyPositionToMessage(&msg, err.node)
+
+	return msg
+}
+
+func (err ImportNotFound) message() string {
+	return fmt.Sprintf(`Cannot find module "%s"`, err.path)
+}
+
+func (err ImportNotFound) Location() lex.Position {
+	return locationOf(err.node)
+}
+
+func (err ImportNotFound) Diagnostic() lex.Diagnostic {
+	return diagnosticAt("import-not-found", err.message(), err.node, 1)
+}
+
+type ImportCycle struct {
+	files []string
+	node  parse.Node
+}
+
+func (err ImportCycle) Error() string {
+	msg := err.message()
+
+	applyPositionToMessage(&msg, err.node)
+
+	return msg
+}
+
+func (err ImportCycle) message() string {
+	files := []string{}
+
+	for _, file := range err.files {
+		files = append(files, displayName(file))
+	}
+
+	return fmt.Sprintf("Import cycle: %s", strings.Join(files, " -> "))
+}
+
+func (err ImportCycle) Location() lex.Position {
+	return locationOf(err.node)
+}
+
+func (err ImportCycle) Diagnostic() lex.Diagnostic {
+	return diagnosticAt("import-cycle", err.message(), err.node, 1)
+}
+
+// Creates a module loader. Imports that can't be found relative
+// to the importing file are looked for in each directory of the
+// search path, in order.
+func NewModules(searchPath []string) *Modules {
+	return &Modules{searchPath: searchPath, loaded: make(map[string]*Module)}
+}
+
+// As NewModules, but modules are compiled and
+// run by a Machine rather than tree-walked.
+func NewCompilingModules(searchPath []string) *Modules {
+	modules := NewModules(searchPath)
+	modules.compile = true
+
+	return modules
+}
+
+// Imports the module at path, as imported by node in the
+// module being evaluated in context.
+func (modules *Modules) Import(path string, node parse.Node, context *Context) (*Module, error) {
+	file, found := modules.resolve(path, context.File)
+
+	if !found {
+		return nil, ImportNotFound{path: path, node: node}
+	}
+
+	for i, loading := range modules.loading {
+		if loading == file {
+			return nil, ImportCycle{files: append(append([]string{}, modules.loading[i:]...), file), node: node}
+		}
+	}
+
+	if err := context.budget.nest(len(modules.loading), node); err != nil {
+		return nil, err
+	}
+
+	return modules.Load(file, context)
+}
+
+// Loads and evaluates the module in file, if it hasn't been
+// already. Its output is written to that of context. Any
+// error is a lex.Diagnostic, or parse.Errors.
+func (modules *Modules) Load(file string, context *Context) (*Module, error) {
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+
+	if module, loaded := modules.loaded[file]; loaded {
+		return module, nil
+	}
+
+	modules.loading = append(modules.loading, file)
+
+	defer func() {
+		modules.loading = modules.loading[0 : len(modules.loading)-1]
+	}()
+
+	code, err := os.Open(file)
+
+	if err != nil {
+		return nil, lex.Diagnose(err)
+	}
+
+	defer code.Close()
+
+	name := displayName(file)
+	parser := parse.NewRecoveringParser(lex.NewJslFileLexer(name, bufio.NewReader(code)))
+
+	ast, err := parser.Parse()
+
+	for _, warning := range parser.Warnings() {
+		context.Error.Write([]byte("Warning: " + warning.String() + "\n"))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	moduleContext := &Context{
+		Table:   NewBuiltinTable(),
+		Input:   context.Input,
+		Output:  context.Output,
+		Error:   context.Error,
+		File:    name,
+		Modules: modules,
+		budget:  context.budget,
+	}
+
+	var evaluator Evaluator = newEvaluator(moduleContext)
+
+	if modules.compile {
+		evaluator = newMachine(moduleContext)
+	}
+
+	if err := evaluator.Evaluate(ast); err != nil {
+		return nil, err
+	}
+
+	module := &Module{file: name, table: moduleContext.Table}
+
+	modules.loaded[file] = module
+
+	return module, nil
+}
+
+// Finds the file for an import of path from the file from.
+// Relative paths are first resolved against from's directory,
+// or the working directory if from is not a file.
+func (modules *Modules) resolve(path string, from string) (string, bool) {
+	if filepath.IsAbs(path) {
+		return path, exists(path)
+	}
+
+	dirs := []string{"."}
+
+	if len(from) > 0 {
+		dirs[0] = filepath.Dir(from)
+	}
+
+	for _, dir := range append(dirs, modules.searchPath...) {
+		if candidate, err := filepath.Abs(filepath.Join(dir, path)); err == nil && exists(candidate) {
+			return candidate, true
+		}
+	}
+
+	return "", false
+}
+
+func exists(file string) bool {
+	info, err := os.Stat(file)
+
+	return err == nil && !info.IsDir()
+}
+
+// Gets the name a module's file is referred to by in
+// errors: relative to the working directory if it is
+// within it, otherwise absolute.
+func displayName(file string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if relative, err := filepath.Rel(wd, file); err == nil && !strings.HasPrefix(relative, "..") {
+			return relative
+		}
+	}
+
+	return file
+}
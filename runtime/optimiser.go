@@ -0,0 +1,90 @@
+package runtime
+
+import (
+	"math"
+
+	"github.com/ehimen/jaslang/parse"
+)
+
+// Simplifies node so that it does less work when evaluated,
+// without changing what it does: operators on literals are
+// replaced by their result, groups by their only child and
+// ifs whose condition is always false are removed. Operators
+// are folded as they'd be evaluated, using those in table.
+// Folded nodes take the place of what they replace, so
+// errors still point to the original code.
+func Optimise(node parse.Node, table *SymbolTable) parse.Node {
+	context := &Context{Table: table}
+
+	return parse.Rewrite(node, func(node parse.Node) parse.Node {
+		switch n := node.(type) {
+		case *parse.Group:
+			if children := n.Children(); len(children) == 1 {
+				return children[0]
+			}
+		case *parse.Operator:
+			if folded, isFolded := fold(n, context); isFolded {
+				return parse.MoveTo(folded, n)
+			}
+		case *parse.If:
+			if condition, isBool := literal(n.Condition()).(Boolean); isBool && !condition.Value {
+				return parse.MoveTo(parse.NewStatement(0, 0), n)
+			}
+		}
+
+		return node
+	})
+}
+
+// Evaluates operator if all of its operands are literals.
+// Operators that fail are left to fail when evaluated.
+func fold(operator *parse.Operator, context *Context) (parse.Node, bool) {
+	args := []Value{}
+	operands := Types{}
+
+	for _, child := range operator.Children() {
+		value := literal(child)
+
+		if value == nil {
+			return nil, false
+		}
+
+		args = append(args, value)
+		operands = append(operands, value.Type())
+	}
+
+	invokable, err := context.Table.Operator(operator.Operator, operands)
+
+	if err != nil {
+		return nil, false
+	}
+
+	if err, result := invokable.Invoke(context, args); err == nil {
+		switch value := result.(type) {
+		case String:
+			return parse.NewString(value.Value, 0, 0), true
+		case Number:
+			if !math.IsInf(value.Value, 0) && !math.IsNaN(value.Value) {
+				return parse.NewNumber(value.Value, 0, 0), true
+			}
+		case Boolean:
+			return parse.NewBoolean(value.Value, 0, 0), true
+		}
+	}
+
+	return nil, false
+}
+
+// Gets the value of a literal node, or nil if node isn't one.
+func literal(node parse.Node) Value {
+	switch n := node.(type) {
+	case *parse.String:
+		return String{Value: n.Value}
+	case *parse.Number:
+		return Number{Value: n.Value}
+	case *parse.Boolean:
+		return Boolean{Value: n.Value}
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package runtime
+
+import (
+	"context"
+
+	"github.com/ehimen/jaslang/parse"
+)
+
+// Code resolved once, and compiled if it's to be run by a
+// Machine, so that it can be run many times without being
+// parsed or resolved again. Each run has its own table, made
+// by Table, and its own I/O. A Program doesn't change once
+// made, so it may be run from many goroutines at once.
+type Program struct {
+	node  parse.Node
+	code  *Bytecode
+	table *SymbolTable
+}
+
+// Resolves node against table, which has the natives and
+// globals the program may use, and compiles it if compiled.
+// Neither node nor table may be changed afterwards. Errors
+// are as for Resolve.
+func NewProgram(node parse.Node, table *SymbolTable, compiled bool) (*Program, error) {
+	if err := Resolve(node, table); err != nil {
+		return nil, err
+	}
+
+	program := &Program{node: node, table: table.Fresh()}
+
+	if compiled {
+		program.code = Compile(node)
+	}
+
+	return program, nil
+}
+
+// Makes a table for one run of the program, as Fresh makes
+// from the table it was resolved against. Globals it was
+// resolved with must be declared in it again before it's run.
+func (p *Program) Table() *SymbolTable {
+	return p.table.Fresh()
+}
+
+// Runs the program in context, whose table must have been
+// made by Table, as EvaluateContext evaluates code.
+func (p *Program) Run(ctx context.Context, context *Context, limits Limits) error {
+	if p.code != nil {
+		return newMachine(context).RunContext(ctx, p.code, limits)
+	}
+
+	return newEvaluator(context).runContext(ctx, p.node, limits)
+}
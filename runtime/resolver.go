@@ -0,0 +1,184 @@
+package runtime
+
+import (
+	"github.com/ehimen/jaslang/lex"
+	"github.com/ehimen/jaslang/parse"
+)
+
+type resolver struct {
+	table *SymbolTable
+	// Identifiers declared by the code being resolved,
+	// which won't be in the table until it's evaluated.
+	declared map[string]bool
+	errors   lex.Diagnostics
+}
+
+// A node to resolve or, once its children have
+// been, to declare what it declares.
+type resolving struct {
+	node     parse.Node
+	declares bool
+}
+
+// Binds each identifier used by node to the slot of its symbol
+// in table, or the nearest of table's parents that has it, so
+// that it can be evaluated without being looked up by name.
+// Each operator, and each match value, is given a call site.
+// Returns every identifier used before it's declared as
+// lex.Diagnostics.
+func Resolve(node parse.Node, table *SymbolTable) error {
+	r := &resolver{table: table, declared: make(map[string]bool)}
+
+	// As evaluation, this doesn't recurse so that
+	// deeply nested code can be resolved.
+	stack := []resolving{{node: node}}
+
+	for len(stack) > 0 {
+		next := stack[len(stack)-1]
+		stack = stack[0 : len(stack)-1]
+
+		if next.declares {
+			r.declare(next.node)
+
+			continue
+		}
+
+		r.use(next.node)
+
+		stack = append(stack, resolving{node: next.node, declares: true})
+
+		children := resolvedChildren(next.node)
+
+		for i := len(children) - 1; i >= 0; i-- {
+			stack = append(stack, resolving{node: children[i]})
+		}
+	}
+
+	if len(r.errors) > 0 {
+		return r.errors
+	}
+
+	return nil
+}
+
+// Gets the nodes within node to resolve, in the
+// order in which they're evaluated.
+func resolvedChildren(node parse.Node) []parse.Node {
+	switch n := node.(type) {
+	case parse.RootNode:
+		statements := []parse.Node{}
+
+		for _, statement := range n.Statements {
+			statements = append(statements, statement)
+		}
+
+		return statements
+	case *parse.If:
+		return append([]parse.Node{n.Condition()}, n.Children()...)
+	case *parse.Match:
+		return append([]parse.Node{n.Subject()}, n.Children()...)
+	case *parse.MatchArm:
+		return append(append([]parse.Node{}, n.Values()...), n.Children()...)
+	case *parse.Try:
+		children := append([]parse.Node{}, n.Children()...)
+
+		if catch := n.Catch(); catch != nil {
+			children = append(children, catch)
+		}
+
+		if finally := n.Finally(); finally != nil {
+			children = append(children, finally)
+		}
+
+		return children
+	case *parse.Operator:
+		// A member isn't a symbol, only what it's a member of.
+		if children := n.Children(); n.Operator == "." && len(children) > 0 {
+			return children[0:1]
+		}
+
+		return n.Children()
+	case parse.ContainsChildren:
+		return n.Children()
+	}
+
+	return nil
+}
+
+// Resolves the identifiers node uses itself, before its children.
+func (r *resolver) use(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.Identifier:
+		r.resolve(n)
+	case *parse.FunctionCall:
+		r.resolve(n.Identifier)
+	case *parse.Assignment:
+		r.resolve(n.Identifier)
+	case *parse.Catch:
+		// The caught error is declared only for the catch's
+		// block, so mustn't hide a symbol declared outside it.
+		if r.isDeclared(n.Identifier.Identifier) {
+			r.errors = append(r.errors, lex.Diagnose(RedeclaredCatch{identifier: n.Identifier}))
+		} else {
+			r.bind(n.Identifier)
+		}
+	case *parse.Operator:
+		n.Site = r.table.reserveSites(1)
+	case *parse.MatchArm:
+		n.BindSites(r.table.reserveSites(len(n.Values())))
+	}
+}
+
+// Declares what node declares, after its children.
+func (r *resolver) declare(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.Let:
+		r.bind(n.Identifier)
+	case *parse.Import:
+		r.bind(n.Alias)
+	case *parse.Catch:
+		// The caught error goes out of scope with the block.
+		if n.Identifier.Resolved {
+			delete(r.declared, n.Identifier.Identifier)
+		}
+	}
+}
+
+func (r *resolver) bind(identifier *parse.Identifier) {
+	r.declared[identifier.Identifier] = true
+	identifier.Bind(0, r.table.slot(identifier.Identifier))
+}
+
+// Whether identifier is declared by the code being
+// resolved so far, or in table or any of its parents.
+func (r *resolver) isDeclared(identifier string) bool {
+	if r.declared[identifier] {
+		return true
+	}
+
+	for scope := r.table; scope != nil; scope = scope.parent {
+		if _, exists := scope.entries[identifier]; exists {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *resolver) resolve(identifier *parse.Identifier) {
+	if r.declared[identifier.Identifier] {
+		identifier.Bind(0, r.table.slot(identifier.Identifier))
+
+		return
+	}
+
+	for depth, scope := 0, r.table; scope != nil; depth, scope = depth+1, scope.parent {
+		if _, exists := scope.entries[identifier.Identifier]; exists {
+			identifier.Bind(depth, scope.slot(identifier.Identifier))
+
+			return
+		}
+	}
+
+	r.errors = append(r.errors, lex.Diagnose(UnknownIdentifier{identifier: identifier.Identifier, node: identifier}))
+}
@@ -1,10 +1,12 @@
 package runtime
 
 import (
-	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/ehimen/jaslang/lex"
 	"github.com/ehimen/jaslang/parse"
 )
 
@@ -12,18 +14,54 @@ type entry struct {
 	identifier string
 	valueType  Type
 	value      Value
+	exported   bool
+	// Whether the entry was added by the host,
+	// rather than declared by code.
+	native bool
 }
 
-type operatorEntry struct {
-	operator  string
-	operands  Types
+// Identifies an operation by its operator and the types
+// of its operands, in order. Operands beyond the first two,
+// which no operator yet has, are joined in to rest.
+type signature struct {
+	operator string
+	arity    int
+	operands [2]Type
+	rest     string
+}
+
+func signatureOf(operator string, arity int, operand func(i int) Type) signature {
+	s := signature{operator: operator, arity: arity}
+
+	for i := 0; i < arity; i++ {
+		if i < len(s.operands) {
+			s.operands[i] = operand(i)
+		} else {
+			s.rest += "\x00" + string(operand(i))
+		}
+	}
+
+	return s
+}
+
+// Caches the operation last dispatched to from one place in
+// the code, which is almost always given the same operands.
+type callSite struct {
+	signature signature
 	operation Invokable
 }
 
 type SymbolTable struct {
-	parent    *SymbolTable
-	entries   map[string]*entry
-	operators []operatorEntry
+	parent  *SymbolTable
+	entries map[string]*entry
+	// Each identifier resolved in the table has a slot, which
+	// holds its entry once it's been declared.
+	slots     []*entry
+	slotIndex map[string]int
+	// How many call sites have been resolved in the table,
+	// each given its own index.
+	sites     int
+	operators map[signature]Invokable
 	types     map[string]Type
 }
 
@@ -33,21 +71,62 @@ type UnknownIdentifier struct {
 }
 
 func (err UnknownIdentifier) Error() string {
-	msg := fmt.Sprintf("Unknown identifier: %s", err.identifier)
+	msg := err.message()
 
 	applyPositionToMessage(&msg, err.node)
 
 	return msg
 }
 
+func (err UnknownIdentifier) message() string {
+	return fmt.Sprintf("Unknown identifier: %s", err.identifier)
+}
+
+func (err UnknownIdentifier) Location() lex.Position {
+	return locationOf(err.node)
+}
+
+func (err UnknownIdentifier) Diagnostic() lex.Diagnostic {
+	return diagnosticAt("unknown-identifier", err.message(), err.node, utf8.RuneCountInString(err.identifier))
+}
+
 type UnknownType struct {
 	identifier string
+	node       parse.Node
 }
 
 func (err UnknownType) Error() string {
+	msg := err.message()
+
+	applyPositionToMessage(&msg, err.node)
+
+	return msg
+}
+
+func (err UnknownType) message() string {
 	return fmt.Sprintf("Unknown type: %s", err.identifier)
 }
 
+func (err UnknownType) Location() lex.Position {
+	return locationOf(err.node)
+}
+
+func (err UnknownType) Diagnostic() lex.Diagnostic {
+	return diagnosticAt("unknown-type", err.message(), err.node, utf8.RuneCountInString(err.identifier))
+}
+
+// Positions an error from looking up a type at the
+// identifier naming it.
+func applyTypeNode(err error, identifier *parse.Identifier) error {
+	if unknownType, isUnknownType := err.(UnknownType); isUnknownType {
+		unknownType.node = identifier
+
+		return unknownType
+	}
+
+	return err
+}
+
 type UnknownOperator struct {
 	operator string
 	operands []Type
@@ -55,17 +134,68 @@ type UnknownOperator struct {
 }
 
 func (err UnknownOperator) Error() string {
+	msg := err.message()
+
+	applyPositionToMessage(&msg, err.node)
+
+	return msg
+}
+
+func (err UnknownOperator) message() string {
+	return fmt.Sprintf("Unknown operator %s with operands (%s)", err.operator, describeOperands(err.operands))
+}
+
+func (err UnknownOperator) Location() lex.Position {
+	return locationOf(err.node)
+}
+
+func (err UnknownOperator) Diagnostic() lex.Diagnostic {
+	return diagnosticAt("unknown-operator", err.message(), err.node, operatorLength(err.operator, err.node))
+}
+
+func describeOperands(operands []Type) string {
 	operandDescription := []string{}
 
-	for _, operand := range err.operands {
+	for _, operand := range operands {
 		operandDescription = append(operandDescription, string(operand))
 	}
 
-	msg := fmt.Sprintf("Unknown operator %s with operands (%s)", err.operator, strings.Join(operandDescription, ", "))
+	return strings.Join(operandDescription, ", ")
+}
 
-	applyPositionToMessage(&msg, err.node)
+// Gets the length of the span of an error from looking up operator
+// at node. That's the operator's when node is where it's written,
+// otherwise node is an operand of an operator that isn't written,
+// such as a match arm's value compared by ==, and it's the
+// operand's: a literal's or identifier's as written.
+func operatorLength(operator string, node parse.Node) int {
+	switch operand := node.(type) {
+	case *parse.Operator:
+		return utf8.RuneCountInString(operator)
+	case *parse.Identifier:
+		return utf8.RuneCountInString(operand.Identifier)
+	case *parse.String:
+		// Escapes aside, as the node doesn't know of them.
+		return utf8.RuneCountInString(operand.Value) + 2
+	case *parse.Number:
+		return len(strconv.FormatFloat(operand.Value, 'f', -1, 64))
+	case *parse.Boolean:
+		return len(strconv.FormatBool(operand.Value))
+	}
 
-	return msg
+	return 1
+}
+
+// Positions an error from looking up an operator at node.
+func applyOperatorNode(err error, node parse.Node) error {
+	switch operatorErr := err.(type) {
+	case UnknownOperator:
+		operatorErr.node = node
+
+		return operatorErr
+	}
+
+	return err
 }
 
 type InvalidType struct {
@@ -76,28 +206,221 @@ type InvalidType struct {
 }
 
 func (err InvalidType) Error() string {
-	msg := fmt.Sprintf(
+	msg := err.message()
+
+	applyPositionToMessage(&msg, err.node)
+
+	return msg
+}
+
+func (err InvalidType) message() string {
+	return fmt.Sprintf(
 		`Invalid value for "%s". Value %s is not of expected type %s`,
 		err.identifier,
 		err.value,
 		err.expectedType,
 	)
+}
+
+func (err InvalidType) Location() lex.Position {
+	return locationOf(err.node)
+}
+
+func (err InvalidType) Diagnostic() lex.Diagnostic {
+	return diagnosticAt("invalid-type", err.message(), err.node, 1)
+}
+
+type UnknownMember struct {
+	member string
+	value  Value
+	node   parse.Node
+}
+
+func (err UnknownMember) Error() string {
+	msg := err.message()
 
 	applyPositionToMessage(&msg, err.node)
 
 	return msg
 }
 
+func (err UnknownMember) message() string {
+	return fmt.Sprintf("Unknown member %s of %s", err.member, err.value.Type())
+}
+
+func (err UnknownMember) Location() lex.Position {
+	return locationOf(err.node)
+}
+
+func (err UnknownMember) Diagnostic() lex.Diagnostic {
+	return diagnosticAt("unknown-member", err.message(), err.node, utf8.RuneCountInString(err.member))
+}
+
+// A catch whose identifier is already declared. The caught
+// error is only in scope in the catch's block, so it can't
+// take the place of a symbol declared outside of it.
+type RedeclaredCatch struct {
+	identifier *parse.Identifier
+}
+
+func (err RedeclaredCatch) Error() string {
+	msg := err.message()
+
+	applyPositionToMessage(&msg, err.identifier)
+
+	return msg
+}
+
+func (err RedeclaredCatch) message() string {
+	return fmt.Sprintf(`Cannot catch into "%s", as it's already declared`, err.identifier.Identifier)
+}
+
+func (err RedeclaredCatch) Location() lex.Position {
+	return locationOf(err.identifier)
+}
+
+func (err RedeclaredCatch) Diagnostic() lex.Diagnostic {
+	return diagnosticAt("redeclared-symbol", err.message(), err.identifier, utf8.RuneCountInString(err.identifier.Identifier))
+}
+
+// A let declaring a symbol that's already declared.
+type RedeclaredSymbol struct {
+	identifier *parse.Identifier
+}
+
+func (err RedeclaredSymbol) Error() string {
+	msg := err.message()
+
+	applyPositionToMessage(&msg, err.identifier)
+
+	return msg
+}
+
+func (err RedeclaredSymbol) message() string {
+	return fmt.Sprintf(`Cannot declare symbol "%s"`, err.identifier.Identifier)
+}
+
+func (err RedeclaredSymbol) Location() lex.Position {
+	return locationOf(err.identifier)
+}
+
+func (err RedeclaredSymbol) Diagnostic() lex.Diagnostic {
+	return diagnosticAt("redeclared-symbol", err.message(), err.identifier, utf8.RuneCountInString(err.identifier.Identifier))
+}
+
 func applyPositionToMessage(msg *string, node parse.Node) {
 	if node == nil {
 		return
 	}
 
-	*msg += fmt.Sprintf(" (position %d, line %d)", node.Column(), node.Line())
+	*msg = fmt.Sprintf("%s: %s", parse.Position(node), *msg)
+}
+
+func locationOf(node parse.Node) lex.Position {
+	if node == nil {
+		return lex.Position{}
+	}
+
+	return parse.Position(node)
+}
+
+func diagnosticAt(code string, message string, node parse.Node, length int) lex.Diagnostic {
+	return lex.Diagnostic{
+		Severity: lex.SeverityError,
+		Code:     code,
+		Message:  message,
+		Span:     lex.SpanAt(locationOf(node), length),
+	}
 }
 
 func NewTable() *SymbolTable {
-	return &SymbolTable{entries: make(map[string]*entry), types: make(map[string]Type)}
+	return &SymbolTable{
+		entries:   make(map[string]*entry),
+		slotIndex: make(map[string]int),
+		operators: make(map[signature]Invokable),
+		types:     make(map[string]Type),
+	}
+}
+
+// Gets the slot of identifier, giving it one if it has none.
+func (table *SymbolTable) slot(identifier string) int {
+	if slot, exists := table.slotIndex[identifier]; exists {
+		return slot
+	}
+
+	table.slotIndex[identifier] = len(table.slots)
+	table.slots = append(table.slots, table.entries[identifier])
+
+	return len(table.slots) - 1
+}
+
+func (table *SymbolTable) define(valueEntry *entry) {
+	table.entries[valueEntry.identifier] = valueEntry
+	table.slots[table.slot(valueEntry.identifier)] = valueEntry
+}
+
+// Creates a table with the types, operators and functions
+// added to this one, but none of the values declared in it,
+// for code resolved against this table to be evaluated with.
+// Identifiers keep their slots. Types and operators are
+// shared rather than copied, so neither table may have more
+// added, and one table may make fresh tables concurrently.
+func (table *SymbolTable) Fresh() *SymbolTable {
+	fresh := NewTable()
+	fresh.types = table.types
+	fresh.operators = table.operators
+	fresh.slots = make([]*entry, len(table.slots))
+	fresh.sites = table.sites
+
+	for identifier, slot := range table.slotIndex {
+		fresh.slotIndex[identifier] = slot
+	}
+
+	for _, found := range table.entries {
+		if found.native {
+			copied := *found
+			fresh.define(&copied)
+		}
+	}
+
+	return fresh
+}
+
+// Gives count new call sites indexes, returning the first.
+func (table *SymbolTable) reserveSites(count int) int {
+	first := table.sites
+	table.sites += count
+
+	return first
+}
+
+// Gets the table depth scopes out from this one.
+func (table *SymbolTable) scope(depth int) *SymbolTable {
+	for ; depth > 0; depth-- {
+		table = table.parent
+	}
+
+	return table
+}
+
+// Gets the entry bound to identifier, if it's been declared.
+// Identifiers that haven't been resolved are looked up by name.
+func (table *SymbolTable) bound(identifier *parse.Identifier) (*entry, bool) {
+	if !identifier.Resolved {
+		found, exists := table.entries[identifier.Identifier]
+
+		return found, exists
+	}
+
+	scope := table.scope(identifier.Depth)
+
+	if identifier.Slot >= len(scope.slots) {
+		return nil, false
+	}
+
+	found := scope.slots[identifier.Slot]
+
+	return found, found != nil
 }
 
 func (table *SymbolTable) AddType(identifier string, p Type) {
@@ -105,72 +428,205 @@ func (table *SymbolTable) AddType(identifier string, p Type) {
 }
 
 func (table *SymbolTable) AddFunction(identifier string, invokable Invokable) {
-	table.entries[identifier] = &entry{identifier: identifier, value: invokable, valueType: TypeInvokable}
+	table.define(&entry{identifier: identifier, value: invokable, valueType: TypeInvokable, native: true})
 }
 
-func (table *SymbolTable) AddOperator(operator string, operands Types, invokable Invokable) {
-	table.operators = append(table.operators, operatorEntry{operator: operator, operands: operands, operation: invokable})
+// Adds an operation for operator with operands, in order. Each
+// operator may have only one operation for the same operands,
+// so that which is used is never ambiguous.
+func (table *SymbolTable) AddOperator(operator string, operands Types, invokable Invokable) error {
+	key := signatureOf(operator, len(operands), func(i int) Type { return operands[i] })
+
+	if _, exists := table.operators[key]; exists {
+		return lex.CodedError{
+			Code:    "redeclared-operator",
+			Message: fmt.Sprintf("Cannot declare operator %s with operands (%s) again", operator, describeOperands(operands)),
+		}
+	}
+
+	table.operators[key] = invokable
+
+	return nil
 }
 
 func (table *SymbolTable) Define(identifier string, t Type) error {
 	if _, exists := table.entries[identifier]; exists {
-		return errors.New(fmt.Sprintf(`Cannot declare symbol "%s"`, identifier))
+		return lex.CodedError{Code: "redeclared-symbol", Message: fmt.Sprintf(`Cannot declare symbol "%s"`, identifier)}
 	}
 
 	value := t.DefaultValue()
 
 	if value == nil {
-		return errors.New(fmt.Sprintf("Type %s cannot have a default value!", t))
+		return lex.CodedError{Code: "no-default-value", Message: fmt.Sprintf("Type %s cannot have a default value!", t)}
 	}
 
-	table.entries[identifier] = &entry{identifier: identifier, valueType: t, value: value}
+	table.define(&entry{identifier: identifier, valueType: t, value: value})
 
 	return nil
 }
 
-func (table *SymbolTable) Set(identifier string, value Value) error {
+// Defines identifier with an initial value. Its type
+// is that of the value.
+func (table *SymbolTable) DefineValue(identifier string, value Value) error {
+	if _, exists := table.entries[identifier]; exists {
+		return lex.CodedError{Code: "redeclared-symbol", Message: fmt.Sprintf(`Cannot declare symbol "%s"`, identifier)}
+	}
+
+	table.define(&entry{identifier: identifier, valueType: value.Type(), value: value})
+
+	return nil
+}
 
+// Marks identifier as being available to
+// other modules that import this table's.
+func (table *SymbolTable) Export(identifier string) error {
 	if valueEntry, exists := table.entries[identifier]; !exists {
 		return UnknownIdentifier{identifier: identifier}
 	} else {
-		if value.Type() != valueEntry.valueType {
-			return InvalidType{
-				identifier:   identifier,
-				value:        value,
-				expectedType: string(valueEntry.valueType),
-			}
+		valueEntry.exported = true
+	}
+
+	return nil
+}
+
+// Gets an exported value.
+func (table *SymbolTable) Exported(identifier string) (Value, bool) {
+	if entry, exists := table.entries[identifier]; exists && entry.exported {
+		return entry.value, true
+	}
+
+	return nil, false
+}
+
+// Gets the values of everything declared by code
+// evaluated with the table, by identifier.
+func (table *SymbolTable) Values() map[string]Value {
+	values := make(map[string]Value)
+
+	for identifier, entry := range table.entries {
+		if !entry.native {
+			values[identifier] = entry.value
 		}
+	}
+
+	return values
+}
+
+func (table *SymbolTable) Set(identifier string, value Value) error {
+	valueEntry, exists := table.entries[identifier]
+
+	return set(identifier, valueEntry, exists, value)
+}
+
+// Holds caught in the slot identifier is bound to, without
+// declaring it by name, so that only the catch block it was
+// resolved in sees it.
+func (table *SymbolTable) catch(identifier *parse.Identifier, caught Error) {
+	scope := table.scope(identifier.Depth)
+	scope.slots[identifier.Slot] = &entry{identifier: identifier.Identifier, valueType: TypeError, value: caught}
+}
 
-		valueEntry.value = value
+// As Set, but by the slot identifier is bound to.
+func (table *SymbolTable) setBound(identifier *parse.Identifier, value Value) error {
+	valueEntry, exists := table.bound(identifier)
 
-		return nil
+	return set(identifier.Identifier, valueEntry, exists, value)
+}
+
+func set(identifier string, valueEntry *entry, exists bool, value Value) error {
+	if !exists {
+		return UnknownIdentifier{identifier: identifier}
 	}
+
+	if value.Type() != valueEntry.valueType {
+		return InvalidType{
+			identifier:   identifier,
+			value:        value,
+			expectedType: string(valueEntry.valueType),
+		}
+	}
+
+	valueEntry.value = value
+
+	return nil
 }
 
 func (table *SymbolTable) Get(identifier string) (Value, error) {
-	if entry, exists := table.entries[identifier]; exists {
-		if entry.value == nil {
-			return nil, errors.New(fmt.Sprintf("Symbol %s has not been initialised", identifier))
-		}
+	entry, exists := table.entries[identifier]
+
+	return get(identifier, entry, exists)
+}
+
+// As Get, but by the slot identifier is bound to.
+func (table *SymbolTable) getBound(identifier *parse.Identifier) (Value, error) {
+	entry, exists := table.bound(identifier)
 
-		return entry.value, nil
+	return get(identifier.Identifier, entry, exists)
+}
+
+func get(identifier string, entry *entry, exists bool) (Value, error) {
+	if !exists {
+		return nil, UnknownIdentifier{identifier: identifier}
 	}
 
-	return nil, UnknownIdentifier{identifier: identifier}
+	if entry.value == nil {
+		return nil, lex.CodedError{Code: "uninitialised-symbol", Message: fmt.Sprintf("Symbol %s has not been initialised", identifier)}
+	}
+
+	return entry.value, nil
 }
 
 func (table *SymbolTable) Operator(operator string, operands Types) (Invokable, error) {
-	for _, candidate := range table.operators {
-		if candidate.operator == operator && operands.Equal(candidate.operands) {
-			return candidate.operation, nil
-		}
+	return table.operator(signatureOf(operator, len(operands), func(i int) Type { return operands[i] }), operands)
+}
+
+func (table *SymbolTable) operator(key signature, operands Types) (Invokable, error) {
+	if operation, exists := table.operators[key]; exists {
+		return operation, nil
 	}
 
-	return nil, UnknownOperator{operator: operator, operands: operands}
+	return nil, UnknownOperator{operator: key.operator, operands: operands}
+}
+
+// As Operator, for the types of args, but first trying the
+// operation last dispatched to from site.
+func (table *SymbolTable) dispatch(site *callSite, operator string, args []Value) (Invokable, error) {
+	key := signatureOf(operator, len(args), func(i int) Type { return args[i].Type() })
+
+	if site.operation != nil && site.signature == key {
+		return site.operation, nil
+	}
+
+	if operation, exists := table.operators[key]; exists {
+		site.signature, site.operation = key, operation
+
+		return operation, nil
+	}
+
+	operands := Types{}
+
+	for _, arg := range args {
+		operands = append(operands, arg.Type())
+	}
+
+	return table.operator(key, operands)
 }
 
 func (table *SymbolTable) Invokable(identifier string) (Invokable, error) {
-	if entry, exists := table.entries[identifier]; exists {
+	entry, exists := table.entries[identifier]
+
+	return invokable(identifier, entry, exists)
+}
+
+// As Invokable, but by the slot identifier is bound to.
+func (table *SymbolTable) invokableBound(identifier *parse.Identifier) (Invokable, error) {
+	entry, exists := table.bound(identifier)
+
+	return invokable(identifier.Identifier, entry, exists)
+}
+
+func invokable(identifier string, entry *entry, exists bool) (Invokable, error) {
+	if exists {
 		if invokable, isInvokable := entry.value.(Invokable); isInvokable {
 			return invokable, nil
 		}
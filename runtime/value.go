@@ -5,6 +5,13 @@ type Value interface {
 	Type() Type
 }
 
+// Implemented by values with members that can
+// be accessed with the "." operator.
+type Accessible interface {
+	Value
+	Member(name string) (Value, bool)
+}
+
 type Void struct {
 }
 
@@ -4,13 +4,38 @@ import (
 	"github.com/ehimen/jaslang/lex"
 )
 
+// Makes a lexeme whose source is its value, such as
+// any but a quoted string, ending where its value does.
 func MakeLexeme(value string, lexemeType lex.LexemeType, position int, line int) lex.Lexeme {
-	return lex.Lexeme{
-		Start: position,
-		Type:  lexemeType,
-		Value: value,
-		Line:  line,
+	lexeme := lex.Lexeme{
+		Start:     position,
+		Type:      lexemeType,
+		Value:     value,
+		Line:      line,
+		EndLine:   line,
+		EndColumn: position,
 	}
+
+	for _, r := range value {
+		if r == '\n' {
+			lexeme.EndLine++
+			lexeme.EndColumn = 1
+		} else {
+			lexeme.EndColumn++
+		}
+	}
+
+	return lexeme
+}
+
+// Makes a lexeme whose source ends somewhere other than its
+// value does, such as a quoted string's closing quote.
+func MakeSourceLexeme(value string, lexemeType lex.LexemeType, position int, line int, endColumn int, endLine int) lex.Lexeme {
+	lexeme := MakeLexeme(value, lexemeType, position, line)
+	lexeme.EndColumn = endColumn
+	lexeme.EndLine = endLine
+
+	return lexeme
 }
 
 // Generates a lexer implementation that simply returns